@@ -0,0 +1,134 @@
+package readline
+
+const (
+	bracketNone      = -1
+	bracketUnmatched = -2
+)
+
+var bracketPairs = map[rune]rune{
+	'(': ')',
+	'[': ']',
+	'{': '}',
+}
+
+// MatchBrackets pairs up the brackets and quotes in line.
+// For every index it returns the index of the partner, -1 if line[i] is
+// not a bracket or quote (or it is inside a quoted string) and -2 if it
+// has no partner.
+// Backslash escapes the next character except inside single quotes, and
+// the line may span several lines.
+func MatchBrackets(line []rune, quotes string) []int {
+	ret := make([]int, len(line))
+	for i := range ret {
+		ret[i] = bracketNone
+	}
+
+	var stack []int
+	var quote rune
+	quoteStart := -1
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		if ch == '\\' && quote != '\'' {
+			i++
+			continue
+		}
+		if quote != 0 {
+			if ch == quote {
+				ret[quoteStart], ret[i] = i, quoteStart
+				quote = 0
+			}
+			continue
+		}
+		if runes.Index(ch, []rune(quotes)) >= 0 {
+			quote, quoteStart = ch, i
+			continue
+		}
+		if _, ok := bracketPairs[ch]; ok {
+			stack = append(stack, i)
+			continue
+		}
+		for open, closing := range bracketPairs {
+			if ch != closing {
+				continue
+			}
+			if len(stack) > 0 && line[stack[len(stack)-1]] == open {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				ret[top], ret[i] = i, top
+			} else {
+				ret[i] = bracketUnmatched
+			}
+			break
+		}
+	}
+	for _, idx := range stack {
+		ret[idx] = bracketUnmatched
+	}
+	if quote != 0 {
+		ret[quoteStart] = bracketUnmatched
+	}
+	return ret
+}
+
+// BracketPainter highlights the bracket or quote under (or right before)
+// the cursor together with its partner, and flags it if it has none.
+//
+// Example:
+//
+//	cfg.Painter = &readline.BracketPainter{}
+type BracketPainter struct {
+	// SGR parameters, "1;4" and "1;31" by default
+	MatchStyle     string
+	UnmatchedStyle string
+	// characters treated as quotes, "\"'`" by default
+	Quotes string
+}
+
+func (p *BracketPainter) quotes() string {
+	if p.Quotes == "" {
+		return "\"'`"
+	}
+	return p.Quotes
+}
+
+func (p *BracketPainter) Paint(line []rune, pos int) []rune {
+	match := MatchBrackets(line, p.quotes())
+
+	idx := -1
+	if pos < len(line) && match[pos] != bracketNone {
+		idx = pos
+	} else if pos > 0 && pos <= len(line) && match[pos-1] != bracketNone {
+		idx = pos - 1
+	}
+	if idx < 0 {
+		return line
+	}
+
+	styles := map[int]string{}
+	if match[idx] == bracketUnmatched {
+		styles[idx] = p.UnmatchedStyle
+		if styles[idx] == "" {
+			styles[idx] = "1;31"
+		}
+	} else {
+		style := p.MatchStyle
+		if style == "" {
+			style = "1;4"
+		}
+		styles[idx] = style
+		styles[match[idx]] = style
+	}
+
+	ret := make([]rune, 0, len(line)+20)
+	for i, r := range line {
+		style, ok := styles[i]
+		if !ok {
+			ret = append(ret, r)
+			continue
+		}
+		ret = append(ret, []rune("\033["+style+"m")...)
+		ret = append(ret, r)
+		ret = append(ret, []rune("\033[0m")...)
+	}
+	return ret
+}
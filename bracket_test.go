@@ -0,0 +1,49 @@
+package readline
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chzyer/test"
+)
+
+func TestMatchBrackets(t *testing.T) {
+	defer test.New(t)
+
+	ret := []struct {
+		Line  string
+		Match []int
+	}{
+		{"a(b)", []int{-1, 3, -1, 1}},
+		{"{[]}", []int{3, 2, 1, 0}},
+		{"(]", []int{-2, -2}},
+		{"\"(\"", []int{2, -1, 0}},
+		{"'\\'", []int{2, -1, 0}},
+		{"\\(()", []int{-1, -1, 3, 2}},
+		{"(\n)", []int{2, -1, 0}},
+		{"\"a", []int{-2, -1}},
+	}
+	for i, r := range ret {
+		test.Equal(MatchBrackets([]rune(r.Line), "\"'"), r.Match, fmt.Errorf("%v", i))
+	}
+}
+
+func TestBracketPainter(t *testing.T) {
+	defer test.New(t)
+
+	p := &BracketPainter{}
+	ret := []struct {
+		Line  string
+		Pos   int
+		Paint string
+	}{
+		{"(a)", 0, "\033[1;4m(\033[0ma\033[1;4m)\033[0m"},
+		{"(a)", 3, "\033[1;4m(\033[0ma\033[1;4m)\033[0m"},
+		{"(a)", 2, "\033[1;4m(\033[0ma\033[1;4m)\033[0m"},
+		{"a(b", 2, "a\033[1;31m(\033[0mb"},
+		{"abc", 1, "abc"},
+	}
+	for i, r := range ret {
+		test.Equal(string(p.Paint([]rune(r.Line), r.Pos)), r.Paint, fmt.Errorf("%v", i))
+	}
+}
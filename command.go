@@ -0,0 +1,332 @@
+package readline
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CommandFunc handles a submitted line dispatched by a Command.
+type CommandFunc func(args *CommandArgs) error
+
+// Command is a node of a command tree, it completes the names, flags and
+// arguments like a PrefixCompleter and dispatches the submitted lines to
+// the Handler of the command they name.
+type Command struct {
+	PrefixCompleter
+	Help    string
+	Handler CommandFunc
+	// Stdout is where the help is written, os.Stdout if nil. It's read
+	// on the root.
+	Stdout io.Writer
+}
+
+// CommandArgs are the parsed words of a dispatched line.
+type CommandArgs struct {
+	Command *Command
+	// Path are the names of the commands from the root
+	Path []string
+	// Args are the positional arguments
+	Args   []string
+	Stdout io.Writer
+
+	flags map[*Flag][]string
+}
+
+// Flag returns the last value of a flag, by its long name or its short
+// letter, and whether it was used.
+func (a *CommandArgs) Flag(name string) (string, bool) {
+	values := a.FlagValues(name)
+	if values == nil {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// FlagValues returns every value of a repeated flag.
+func (a *CommandArgs) FlagValues(name string) []string {
+	for f, values := range a.flags {
+		if f.Long == name || (f.Short != 0 && string(f.Short) == name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// UnknownCommandError is returned by Dispatch when a word doesn't name a
+// command.
+type UnknownCommandError struct {
+	Name string
+	// Suggestions are the closest command names
+	Suggestions []string
+}
+
+func (e *UnknownCommandError) Error() string {
+	msg := fmt.Sprintf("unknown command %q", e.Name)
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf(", did you mean %q?", strings.Join(e.Suggestions, `", "`))
+	}
+	return msg
+}
+
+// NewCommandTree returns the root of a command tree, with a generated
+// help command.
+func NewCommandTree(children ...PrefixCompleterInterface) *Command {
+	root := &Command{PrefixCompleter: PrefixCompleter{Name: []rune(" ")}}
+	help := CmdItem("help", "show the help of a command", func(args *CommandArgs) error {
+		node, path := root, []string{}
+		for _, name := range args.Args {
+			child := node.command(name)
+			if child == nil {
+				return node.unknown(name)
+			}
+			node, path = child, append(path, name)
+		}
+		_, err := io.WriteString(args.Stdout, node.Usage(path))
+		return err
+	})
+	help.Args = []*Arg{{
+		Name: "command",
+		Values: []PrefixCompleterInterface{PcItemDynamic(func(line string) []string {
+			words := SplitWords([]rune(line))
+			node := root
+			for _, w := range words[1 : len(words)-1] {
+				child := node.command(string(w.Value))
+				if child == nil {
+					return nil
+				}
+				node = child
+			}
+			return node.names()
+		})},
+		Repeat: true,
+	}}
+	root.Children = append(append([]PrefixCompleterInterface{}, children...), help)
+	return root
+}
+
+// CmdItem returns a Command, like PcItem.
+func CmdItem(name, help string, handler CommandFunc, children ...PrefixCompleterInterface) *Command {
+	return &Command{
+		PrefixCompleter: PrefixCompleter{
+			Name:     []rune(name + " "),
+			Children: children,
+		},
+		Help:    help,
+		Handler: handler,
+	}
+}
+
+func (c *Command) GetHelp() string {
+	return c.Help
+}
+
+// names returns the names of the children.
+func (c *Command) names() []string {
+	ret := []string{}
+	for _, child := range c.Children {
+		if name := strings.TrimSpace(string(child.GetName())); name != "" {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// command returns the child Command named name, the dynamic children
+// aren't called.
+func (c *Command) command(name string) *Command {
+	for _, child := range c.Children {
+		if cmd, ok := child.(*Command); ok && strings.TrimSpace(string(cmd.Name)) == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func (c *Command) unknown(name string) error {
+	e := &UnknownCommandError{Name: name}
+	for _, n := range c.names() {
+		if strings.HasPrefix(n, name) || editDistance(n, name) <= 2 {
+			e.Suggestions = append(e.Suggestions, n)
+		}
+	}
+	return e
+}
+
+// Dispatch parses line and calls the Handler of the command it names,
+// an empty line is ignored.
+func (c *Command) Dispatch(line string) error {
+	words := SplitWords([]rune(line))
+	if w := words[len(words)-1]; w.Start == w.End {
+		words = words[:len(words)-1]
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	stdout := c.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	args := &CommandArgs{Command: c, Stdout: stdout, flags: map[*Flag][]string{}}
+	flags := c.Flags
+	dashdash := false
+	for i := 0; i < len(words); i++ {
+		node := args.Command
+		word := string(words[i].Value)
+		switch {
+		case !dashdash && word == "--":
+			dashdash = true
+		case !dashdash && len(word) > 1 && word[0] == '-':
+			f, hasValue := lookupFlag(flags, word, false)
+			if f == nil {
+				return fmt.Errorf("unknown flag %q", word)
+			}
+			value := ""
+			if hasValue {
+				value = word[strings.Index(word, "=")+1:]
+			} else if f.takesValue() {
+				if i+1 == len(words) {
+					return fmt.Errorf("flag %q needs a value", word)
+				}
+				i++
+				value = string(words[i].Value)
+			}
+			args.flags[f] = append(args.flags[f], value)
+		case len(args.Args) == 0 && len(node.Children) > 0:
+			if child := node.command(word); child != nil {
+				args.Command = child
+				args.Path = append(args.Path, word)
+				flags = append(persistentFlags(flags), child.Flags...)
+				break
+			}
+			if len(node.Args) == 0 {
+				return node.unknown(word)
+			}
+			args.Args = append(args.Args, word)
+		default:
+			args.Args = append(args.Args, word)
+		}
+	}
+
+	node := args.Command
+	if node.Handler == nil {
+		if len(args.Path) == 0 {
+			return fmt.Errorf("missing command")
+		}
+		return fmt.Errorf("%s: missing command", strings.Join(args.Path, " "))
+	}
+	if n := len(node.Args); n > 0 && len(args.Args) > n && !node.Args[n-1].Repeat {
+		return fmt.Errorf("%s: too many arguments", strings.Join(args.Path, " "))
+	}
+	return node.Handler(args)
+}
+
+// Usage returns the help of the command at path.
+func (c *Command) Usage(path []string) string {
+	buf := bytes.NewBuffer(nil)
+	if len(path) > 0 {
+		buf.WriteString("usage: " + strings.Join(path, " "))
+		if len(c.Flags) > 0 {
+			buf.WriteString(" [flags]")
+		}
+		if len(c.Children) > 0 {
+			buf.WriteString(" <command>")
+		}
+		for _, arg := range c.Args {
+			buf.WriteString(" <" + arg.Name + ">")
+			if arg.Repeat {
+				buf.WriteString("...")
+			}
+		}
+		buf.WriteString("\n")
+		if c.Help != "" {
+			buf.WriteString("\n" + c.Help + "\n")
+		}
+	}
+
+	rows := [][2]string{}
+	for _, f := range c.Flags {
+		name := "    "
+		if f.Short != 0 {
+			name = "-" + string(f.Short) + ", "
+		}
+		if f.Long != "" {
+			name += "--" + f.Long
+		}
+		if f.takesValue() {
+			name += "=VALUE"
+		}
+		rows = append(rows, [2]string{name, f.Description})
+	}
+	writeHelpRows(buf, "Flags", rows)
+
+	rows = rows[:0]
+	for _, child := range c.Children {
+		name := strings.TrimSpace(string(child.GetName()))
+		if name != "" {
+			rows = append(rows, [2]string{name, describe(child)})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+	writeHelpRows(buf, "Commands", rows)
+	return buf.String()
+}
+
+func writeHelpRows(buf *bytes.Buffer, title string, rows [][2]string) {
+	if len(rows) == 0 {
+		return
+	}
+	width := 0
+	for _, row := range rows {
+		if w := runes.WidthAll([]rune(row[0])); w > width {
+			width = w
+		}
+	}
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString(title + ":\n")
+	for _, row := range rows {
+		line := "  " + row[0]
+		if row[1] != "" {
+			line += strings.Repeat(" ", width-runes.WidthAll([]rune(row[0]))+2) + row[1]
+		}
+		buf.WriteString(line + "\n")
+	}
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
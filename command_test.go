@@ -0,0 +1,120 @@
+package readline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chzyer/test"
+)
+
+func TestCommandTree(t *testing.T) {
+	defer test.New(t)
+
+	var got *CommandArgs
+	handler := func(args *CommandArgs) error {
+		got = args
+		return nil
+	}
+	commit := CmdItem("commit", "record changes", handler)
+	commit.Flags = []*Flag{
+		{Long: "message", Short: 'm', HasValue: true, Description: "commit message"},
+		{Long: "all", Short: 'a'},
+	}
+	commit.Args = []*Arg{{Name: "file", Repeat: true}}
+	git := CmdItem("git", "the stupid content tracker", nil,
+		commit,
+		CmdItem("checkout", "switch branches", handler),
+	)
+	git.Flags = []*Flag{{Long: "verbose", Short: 'v', Persistent: true}}
+	out := bytes.NewBuffer(nil)
+	root := NewCommandTree(git, CmdItem("exit", "leave", handler))
+	root.Stdout = out
+
+	test.Nil(root.Dispatch(`git -v commit -a --message "first one" a.go b.go`))
+	test.Equal(got.Command, commit)
+	test.Equal(got.Path, []string{"git", "commit"})
+	test.Equal(got.Args, []string{"a.go", "b.go"})
+	msg, ok := got.Flag("m")
+	test.Equal(msg, "first one")
+	test.Equal(ok, true)
+	_, ok = got.Flag("all")
+	test.Equal(ok, true)
+	_, ok = got.Flag("verbose")
+	test.Equal(ok, true)
+
+	test.Nil(root.Dispatch("git commit --message=x"))
+	test.Equal(got.FlagValues("message"), []string{"x"})
+	test.Nil(root.Dispatch("   "))
+
+	for i, r := range []struct {
+		Line string
+		Err  string
+	}{
+		{"git comit", `unknown command "comit", did you mean "commit"?`},
+		{"git ch", `unknown command "ch", did you mean "checkout"?`},
+		{"gti", `unknown command "gti", did you mean "git"?`},
+		{"git", "git: missing command"},
+		{"git commit --amend", `unknown flag "--amend"`},
+		{"git commit -m", `flag "-m" needs a value`},
+		{"exit -v", `unknown flag "-v"`},
+	} {
+		test.Equal(fmt.Sprint(root.Dispatch(r.Line)), r.Err, fmt.Errorf("%v", i))
+	}
+
+	// completion
+	newLine, _ := root.Do([]rune("git c"), 5)
+	test.Equal(rs(newLine), []string{"ommit ", "heckout "})
+	newLine, _ = root.Do([]rune("git commit --m"), 14)
+	test.Equal(rs(newLine), []string{"essage="})
+	candidates, _ := root.DoCandidates([]rune("e"), 1)
+	test.Equal(candidates[0].Description, "leave")
+	newLine, _ = root.Do([]rune("help git "), 9)
+	test.Equal(rs(newLine), []string{"commit ", "checkout "})
+
+	// help
+	test.Nil(root.Dispatch("help git commit"))
+	test.Equal(out.String(), ""+
+		"usage: git commit [flags] <file>...\n"+
+		"\n"+
+		"record changes\n"+
+		"\n"+
+		"Flags:\n"+
+		"  -m, --message=VALUE  commit message\n"+
+		"  -a, --all\n")
+	out.Reset()
+	test.Nil(root.Dispatch("help"))
+	test.Equal(strings.Split(out.String(), "\n")[:4], []string{
+		"Commands:",
+		"  exit  leave",
+		"  git   the stupid content tracker",
+		"  help  show the help of a command",
+	})
+}
+
+func TestCommandTreeStatic(t *testing.T) {
+	defer test.New(t)
+
+	// the children of the caller are kept
+	children := make([]PrefixCompleterInterface, 1, 2)
+	children[0] = CmdItem("exit", "leave", nil)
+	NewCommandTree(children...)
+	test.Equal(children[:2][1] == nil, true)
+
+	// dispatching doesn't call the dynamic children
+	calls := 0
+	var got *CommandArgs
+	open := CmdItem("open", "open a file", func(args *CommandArgs) error {
+		got = args
+		return nil
+	}, PcItemDynamic(func(line string) []string {
+		calls++
+		return []string{"a.txt"}
+	}))
+	open.Args = []*Arg{{Name: "file"}}
+	root := NewCommandTree(open)
+	test.Nil(root.Dispatch("open a.txt"))
+	test.Equal(got.Args, []string{"a.txt"})
+	test.Equal(calls, 0)
+}
@@ -1,10 +1,10 @@
 package readline
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
 )
 
 type AutoCompleter interface {
@@ -31,11 +31,23 @@ type opCompleter struct {
 
 	inCompleteMode  bool
 	inSelectMode    bool
-	candidate       [][]rune
+	inQueryMode     bool
+	candidate       []Candidate
 	candidateSource []rune
 	candidateOff    int
 	candidateChoise int
-	candidateColNum int
+	// the row and column of each candidate in the list
+	candidateRows []int
+	candidateCols []int
+	// the first row of candidates shown when they don't fit the screen
+	candidateRowOff int
+
+	// the running ContextCompleter call
+	pending *asyncComplete
+
+	// the buffer without the preview in the menu select mode
+	menuBase []rune
+	menuIdx  int
 }
 
 func newOpCompleter(w io.Writer, op *Operation, width int) *opCompleter {
@@ -46,9 +58,18 @@ func newOpCompleter(w io.Writer, op *Operation, width int) *opCompleter {
 	}
 }
 
+// applyCandidate writes the candidate into the buffer.
+func (o *opCompleter) applyCandidate(c *Candidate) {
+	if c.Replace {
+		o.op.buf.ReplaceRange(c.Start, c.End, c.Insert)
+		return
+	}
+	o.op.buf.WriteRunes(c.Insert)
+}
+
 func (o *opCompleter) doSelect() {
 	if len(o.candidate) == 1 {
-		o.op.buf.WriteRunes(o.candidate[0])
+		o.applyCandidate(&o.candidate[0])
 		o.ExitCompleteMode(false)
 		return
 	}
@@ -73,6 +94,10 @@ func (o *opCompleter) OnComplete() bool {
 		return true
 	}
 
+	if o.op.cfg.ExpandOnTab && !o.IsInCompleteMode() && o.expandWord() {
+		return true
+	}
+
 	buf := o.op.buf
 	rs := buf.Runes()
 
@@ -84,29 +109,47 @@ func (o *opCompleter) OnComplete() bool {
 
 	o.ExitCompleteSelectMode()
 	o.candidateSource = rs
-	newLines, offset := o.op.cfg.AutoComplete.Do(rs, buf.idx)
-	if len(newLines) == 0 {
+	if c, ok := o.op.cfg.AutoComplete.(ContextCompleter); ok {
+		o.completeAsync(c, rs, buf.idx, func(candidates []Candidate, offset int) {
+			o.complete(candidates, offset)
+		})
+		return true
+	}
+	candidates, offset := doCandidates(o.op.cfg.AutoComplete, rs, buf.idx, o.matchOptions())
+	return o.complete(candidates, offset)
+}
+
+// complete shows the candidates or writes the one which matches.
+func (o *opCompleter) complete(candidates []Candidate, offset int) bool {
+	buf := o.op.buf
+	if len(candidates) == 0 {
 		o.ExitCompleteMode(false)
 		return true
 	}
 
 	// only Aggregate candidates in non-complete mode
 	if !o.IsInCompleteMode() {
-		if len(newLines) == 1 {
-			buf.WriteRunes(newLines[0])
+		if len(candidates) == 1 {
+			o.applyCandidate(&candidates[0])
 			o.ExitCompleteMode(false)
 			return true
 		}
 
-		same, size := runes.Aggregate(newLines)
+		fold := o.op.cfg.CompletionIgnoreCase
+		same, size := aggregateCandidates(candidates, fold)
 		if size > 0 {
 			buf.WriteRunes(same)
 			o.ExitCompleteMode(false)
 			return true
 		}
+		if start, end, same, ok := aggregateReplace(buf.Runes(), candidates, fold); ok {
+			buf.ReplaceRange(start, end, same)
+			o.ExitCompleteMode(false)
+			return true
+		}
 	}
 
-	o.EnterCompleteMode(offset, newLines)
+	o.EnterCompleteMode(offset, candidates)
 	return true
 }
 
@@ -114,26 +157,47 @@ func (o *opCompleter) IsInCompleteSelectMode() bool {
 	return o.inSelectMode
 }
 
+// IsInCompleteQueryMode reports whether readline is asking whether to
+// display all the candidates.
+func (o *opCompleter) IsInCompleteQueryMode() bool {
+	return o.inQueryMode
+}
+
+// HandleCompleteQuery answers "Display all N possibilities? (y or n)",
+// other keys are ignored.
+func (o *opCompleter) HandleCompleteQuery(r rune) {
+	switch r {
+	case 'y', 'Y', ' ', CharTab:
+		o.inQueryMode = false
+		if o.op.cfg.MenuSelect {
+			o.enterMenu()
+			return
+		}
+		o.CompleteRefresh()
+	case 'n', 'N', CharBackspace, CharBell, CharInterrupt:
+		o.ExitCompleteMode(true)
+		o.op.buf.Refresh(nil)
+	}
+}
+
 func (o *opCompleter) IsInCompleteMode() bool {
 	return o.inCompleteMode
 }
 
 func (o *opCompleter) HandleCompleteSelect(r rune) bool {
+	if o.inMenu() {
+		return o.handleMenuSelect(r)
+	}
 	next := true
 	switch r {
 	case CharEnter, CharCtrlJ:
 		next = false
-		o.op.buf.WriteRunes(o.op.candidate[o.op.candidateChoise])
+		o.applyCandidate(&o.candidate[o.candidateChoise])
 		o.ExitCompleteMode(false)
 	case CharLineStart:
-		num := o.candidateChoise % o.candidateColNum
-		o.nextCandidate(-num)
+		o.candidateChoise = o.rowEdge(o.candidateChoise, false)
 	case CharLineEnd:
-		num := o.candidateColNum - o.candidateChoise%o.candidateColNum - 1
-		o.candidateChoise += num
-		if o.candidateChoise >= len(o.candidate) {
-			o.candidateChoise = len(o.candidate) - 1
-		}
+		o.candidateChoise = o.rowEdge(o.candidateChoise, true)
 	case CharBackspace:
 		o.ExitCompleteSelectMode()
 		next = false
@@ -143,25 +207,11 @@ func (o *opCompleter) HandleCompleteSelect(r rune) bool {
 		o.ExitCompleteMode(true)
 		next = false
 	case CharNext:
-		tmpChoise := o.candidateChoise + o.candidateColNum
-		if tmpChoise >= o.getMatrixSize() {
-			tmpChoise -= o.getMatrixSize()
-		} else if tmpChoise >= len(o.candidate) {
-			tmpChoise += o.candidateColNum
-			tmpChoise -= o.getMatrixSize()
-		}
-		o.candidateChoise = tmpChoise
+		o.moveRow(1)
 	case CharBackward:
 		o.nextCandidate(-1)
 	case CharPrev:
-		tmpChoise := o.candidateChoise - o.candidateColNum
-		if tmpChoise < 0 {
-			tmpChoise += o.getMatrixSize()
-			if tmpChoise >= len(o.candidate) {
-				tmpChoise -= o.candidateColNum
-			}
-		}
-		o.candidateChoise = tmpChoise
+		o.moveRow(-1)
 	default:
 		next = false
 		o.ExitCompleteSelectMode()
@@ -173,55 +223,193 @@ func (o *opCompleter) HandleCompleteSelect(r rune) bool {
 	return false
 }
 
-func (o *opCompleter) getMatrixSize() int {
-	line := len(o.candidate) / o.candidateColNum
-	if len(o.candidate)%o.candidateColNum != 0 {
-		line++
+// rowEdge returns the first or the last candidate in the row of idx.
+func (o *opCompleter) rowEdge(idx int, last bool) int {
+	if idx < 0 || idx >= len(o.candidateRows) {
+		return idx
+	}
+	step := -1
+	if last {
+		step = 1
+	}
+	row := o.candidateRows[idx]
+	for next := idx + step; next >= 0 && next < len(o.candidateRows) && o.candidateRows[next] == row; next += step {
+		idx = next
+	}
+	return idx
+}
+
+// moveRow selects the candidate in the same column of the next (dir 1) or
+// the previous (dir -1) row, wrapping around. The rows of the same group
+// without that column are skipped, in another group the last column of a
+// narrower row is selected.
+func (o *opCompleter) moveRow(dir int) {
+	cur := o.candidateChoise
+	n := len(o.candidate)
+	if cur < 0 || cur >= len(o.candidateRows) || n != len(o.candidateRows) {
+		return
+	}
+	row, col := o.candidateRows[cur], o.candidateCols[cur]
+	for i := 1; i < n; i++ {
+		idx := ((cur+dir*i)%n + n) % n
+		if o.candidateRows[idx] == row {
+			continue
+		}
+		if last := o.rowEdge(idx, true); o.candidateCols[last] < col {
+			if o.candidate[idx].Group == o.candidate[cur].Group {
+				continue
+			}
+			o.candidateChoise = last
+			return
+		}
+		if o.candidateCols[idx] == col {
+			o.candidateChoise = idx
+			return
+		}
 	}
-	return line * o.candidateColNum
 }
 
 func (o *opCompleter) OnWidthChange(newWidth int) {
 	o.width = newWidth
 }
 
+// candidateDisplay returns how the candidate is shown in the list.
+func (o *opCompleter) candidateDisplay(c *Candidate, same []rune) string {
+	if c.Display != "" {
+		return c.Display
+	}
+	if c.Replace {
+		return string(c.Insert)
+	}
+	return string(same) + string(c.Insert)
+}
+
+// highlightMatched underlines the runes of display at matched.
+func highlightMatched(display string, matched []int) string {
+	if len(matched) == 0 {
+		return display
+	}
+	buf := bytes.NewBuffer(nil)
+	for idx, r := range []rune(display) {
+		if len(matched) > 0 && matched[0] == idx {
+			matched = matched[1:]
+			buf.WriteString("\033[4m" + string(r) + "\033[24m")
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
 func (o *opCompleter) CompleteRefresh() {
 	if !o.inCompleteMode {
 		return
 	}
-	lineCnt := o.op.buf.CursorLineCount()
+	if o.inQueryMode {
+		o.op.buf.SetBelow(BelowComplete, fmt.Sprintf(
+			"Display all %d possibilities? (y or n)", len(o.candidate)))
+		o.op.buf.Refresh(nil)
+		return
+	}
+	same := o.op.buf.RuneSlice(-o.candidateOff)
+	if o.inMenu() && o.candidateOff <= o.menuIdx {
+		same = o.menuBase[o.menuIdx-o.candidateOff : o.menuIdx]
+	}
+	displays := make([]string, len(o.candidate))
+	widths := make([]int, len(o.candidate))
+	for idx := range o.candidate {
+		displays[idx] = o.candidateDisplay(&o.candidate[idx], same)
+		widths[idx] = runes.WidthAll(runes.ColorFilter([]rune(displays[idx])))
+	}
+
+	o.candidateRows = make([]int, len(o.candidate))
+	o.candidateCols = make([]int, len(o.candidate))
+	buf := bytes.NewBuffer(nil)
+	row := 0
+	groups := candidateGroups(o.candidate)
+	for _, g := range groups {
+		// the headers are only useful between groups
+		if len(groups) > 1 && g.name != "" {
+			if o.op.cfg.TermCaps.HasColor() {
+				buf.WriteString("\033[1m" + g.name + "\033[0m\n")
+			} else {
+				buf.WriteString(g.name + "\n")
+			}
+			row++
+		}
+		row = o.layoutGroup(buf, g.start, g.end, displays, widths, row)
+	}
+
+	if o.op.cfg.TermCaps.IsDumb() {
+		// there's no way back from below the line, list the candidates
+		// above it like any other output and leave the complete mode.
+		o.op.buf.Refresh(func() {
+			o.w.Write([]byte(strings.TrimSuffix(buf.String(), "\n") + "\n"))
+		})
+		o.ExitCompleteMode(false)
+		return
+	}
+	rows := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	o.op.buf.SetBelow(BelowComplete, strings.Join(o.viewport(rows), "\n"))
+	o.op.buf.Refresh(nil)
+}
+
+// layoutGroup writes the candidates from start to end in columns, or one
+// per line with their descriptions, from the row row. It returns the row
+// after them.
+func (o *opCompleter) layoutGroup(buf *bytes.Buffer, start, end int, displays []string, widths []int, row int) int {
 	colWidth := 0
-	for _, c := range o.candidate {
-		w := runes.WidthAll(c)
-		if w > colWidth {
-			colWidth = w
+	hasDesc := false
+	for idx := start; idx < end; idx++ {
+		if widths[idx] > colWidth {
+			colWidth = widths[idx]
+		}
+		if o.candidate[idx].Description != "" {
+			hasDesc = true
 		}
 	}
-	colWidth += o.candidateOff + 1
-	same := o.op.buf.RuneSlice(-o.candidateOff)
+	colWidth++
 
 	// -1 to avoid reach the end of line
 	width := o.width - 1
-	colNum := width / colWidth
-	if colNum != 0 {
-		colWidth += (width - (colWidth * colNum)) / colNum
+	colNum := 1
+	if !hasDesc && !o.op.cfg.MenuSelect {
+		colNum = width / colWidth
+		if colNum != 0 {
+			colWidth += (width - (colWidth * colNum)) / colNum
+		} else {
+			colNum = 1
+		}
+	} else {
+		// one candidate per line, with the descriptions aligned after them
+		colWidth++
 	}
 
-	o.candidateColNum = colNum
-	buf := bufio.NewWriter(o.w)
-	buf.Write(bytes.Repeat([]byte("\n"), lineCnt))
-
 	colIdx := 0
-	lines := 1
-	buf.WriteString("\033[J")
-	for idx, c := range o.candidate {
+	for idx := start; idx < end; idx++ {
+		c := o.candidate[idx]
+		o.candidateRows[idx], o.candidateCols[idx] = row, colIdx
 		inSelect := idx == o.candidateChoise && o.IsInCompleteSelectMode()
 		if inSelect {
 			buf.WriteString("\033[30;47m")
 		}
-		buf.WriteString(string(same))
-		buf.WriteString(string(c))
-		buf.Write(bytes.Repeat([]byte(" "), colWidth-runes.WidthAll(c)-runes.WidthAll(same)))
+		if o.op.cfg.TermCaps.HasColor() {
+			buf.WriteString(highlightMatched(displays[idx], c.Matched))
+		} else {
+			buf.WriteString(displays[idx])
+		}
+		if hasDesc {
+			desc := []rune(c.Description)
+			for len(desc) > 0 && colWidth+runes.WidthAll(desc) > width {
+				desc = desc[:len(desc)-1]
+			}
+			if len(desc) > 0 {
+				buf.Write(bytes.Repeat([]byte(" "), colWidth-widths[idx]))
+				buf.WriteString(string(desc))
+			}
+		} else {
+			buf.Write(bytes.Repeat([]byte(" "), colWidth-widths[idx]))
+		}
 
 		if inSelect {
 			buf.WriteString("\033[0m")
@@ -230,15 +418,103 @@ func (o *opCompleter) CompleteRefresh() {
 		colIdx++
 		if colIdx == colNum {
 			buf.WriteString("\n")
-			lines++
 			colIdx = 0
+			row++
+		}
+	}
+	if colIdx != 0 {
+		buf.WriteString("\n")
+		row++
+	}
+	return row
+}
+
+// viewport cuts the rows of candidates to the screen height, keeping the
+// selected one visible, and adds a --More-- row if some are hidden.
+func (o *opCompleter) viewport(rows []string) []string {
+	height := -1
+	if o.op.cfg.FuncGetHeight != nil {
+		height = o.op.cfg.FuncGetHeight()
+	}
+	// leave the edit line, the other sections below it (e.g. the status)
+	// and a row for the --More--
+	size := height - o.op.buf.LineCount(-1) - o.op.buf.BelowRows(BelowComplete) - 1
+	if height <= 0 || len(rows) <= size+1 {
+		o.candidateRowOff = 0
+		return rows
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	if o.candidateChoise >= 0 && o.candidateChoise < len(o.candidateRows) {
+		row := o.candidateRows[o.candidateChoise]
+		if row < o.candidateRowOff {
+			o.candidateRowOff = row
+		} else if row >= o.candidateRowOff+size {
+			o.candidateRowOff = row - size + 1
 		}
 	}
+	if o.candidateRowOff > len(rows)-size {
+		o.candidateRowOff = len(rows) - size
+	}
+	ret := make([]string, 0, size+1)
+	ret = append(ret, rows[o.candidateRowOff:o.candidateRowOff+size]...)
+	return append(ret, fmt.Sprintf("--More-- (%d-%d of %d rows)",
+		o.candidateRowOff+1, o.candidateRowOff+size, len(rows)))
+}
 
-	// move back
-	fmt.Fprintf(buf, "\033[%dA\r", lineCnt-1+lines)
-	fmt.Fprintf(buf, "\033[%dC", o.op.buf.idx+o.op.buf.PromptLen())
-	buf.Flush()
+func (o *opCompleter) matchOptions() matchOptions {
+	return matchOptions{fold: o.op.cfg.CompletionIgnoreCase, history: o.op.history}
+}
+
+// aggregateCandidates returns the text shared by the beginning of all
+// the candidates, there's none if one of them replaces the typed text.
+func aggregateCandidates(candidates []Candidate, fold bool) ([]rune, int) {
+	for _, c := range candidates {
+		if c.Replace {
+			return nil, 0
+		}
+	}
+	same := aggregateRunes(CandidateInserts(candidates), fold)
+	return same, len(same)
+}
+
+// aggregateRunes returns the beginning shared by all the candidates, with
+// the case of the first one if fold is set.
+func aggregateRunes(candidates [][]rune, fold bool) []rune {
+	size := 0
+	for ; size < len(candidates[0]); size++ {
+		for _, c := range candidates[1:] {
+			if size >= len(c) || !runes.EqualRune(c[size], candidates[0][size], fold) {
+				return runes.Copy(candidates[0][:size])
+			}
+		}
+	}
+	return runes.Copy(candidates[0])
+}
+
+// aggregateReplace returns the text shared by the beginning of candidates
+// which all replace the same range of line, if it extends the replaced
+// runes ignoring the case.
+func aggregateReplace(line []rune, candidates []Candidate, fold bool) (start, end int, same []rune, ok bool) {
+	start, end = candidates[0].Start, candidates[0].End
+	inserts := make([][]rune, len(candidates))
+	for idx, c := range candidates {
+		if !c.Replace || c.Start != start || c.End != end {
+			return 0, 0, nil, false
+		}
+		inserts[idx] = c.Insert
+	}
+	if start < 0 || end > len(line) || start > end {
+		return 0, 0, nil, false
+	}
+	same = aggregateRunes(inserts, fold)
+	replaced := line[start:end]
+	if len(same) <= len(replaced) || !runes.HasPrefixFold(same, replaced) {
+		return 0, 0, nil, false
+	}
+	return start, end, same, true
 }
 
 func (o *opCompleter) aggCandidate(candidate [][]rune) int {
@@ -264,10 +540,19 @@ func (o *opCompleter) EnterCompleteSelectMode() {
 	o.CompleteRefresh()
 }
 
-func (o *opCompleter) EnterCompleteMode(offset int, candidate [][]rune) {
+func (o *opCompleter) EnterCompleteMode(offset int, candidate []Candidate) {
+	// only ask before the first listing, not while the user narrows it
+	if n := o.op.cfg.CompletionQueryItems; n > 0 && len(candidate) >= n && !o.inCompleteMode {
+		o.inQueryMode = true
+	}
 	o.inCompleteMode = true
-	o.candidate = candidate
+	o.candidate = sortGroups(candidate, o.op.cfg.CompletionGroupOrder)
 	o.candidateOff = offset
+	o.candidateRowOff = 0
+	if o.op.cfg.MenuSelect && !o.inQueryMode && !o.inMenu() {
+		o.enterMenu()
+		return
+	}
 	o.CompleteRefresh()
 }
 
@@ -277,9 +562,12 @@ func (o *opCompleter) ExitCompleteSelectMode() {
 	o.candidateChoise = -1
 	o.candidateOff = -1
 	o.candidateSource = nil
+	o.menuBase = nil
 }
 
 func (o *opCompleter) ExitCompleteMode(revent bool) {
 	o.inCompleteMode = false
+	o.inQueryMode = false
 	o.ExitCompleteSelectMode()
+	o.op.buf.ClearBelow(BelowComplete)
 }
@@ -0,0 +1,138 @@
+package readline
+
+import (
+	"context"
+	"time"
+)
+
+// ContextCompleter can be implemented by an AutoCompleter which may be
+// slow, readline runs it off the input loop with a spinner and cancels ctx
+// when the user types or presses Ctrl-C, or when Config.CompleteTimeout
+// expires. The results are dropped if the line changed in the meantime.
+type ContextCompleter interface {
+	DoContext(ctx context.Context, line []rune, pos int) (candidates []Candidate, length int)
+}
+
+type dumpContextCompleter struct {
+	f func(context.Context, []rune, int) ([]Candidate, int)
+}
+
+func (d *dumpContextCompleter) DoContext(ctx context.Context, line []rune, pos int) ([]Candidate, int) {
+	return d.f(ctx, line, pos)
+}
+
+func (d *dumpContextCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	return d.f(context.Background(), line, pos)
+}
+
+func (d *dumpContextCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	candidates, length := d.DoCandidates(line, pos)
+	return CandidateInserts(candidates), length
+}
+
+// ContextFunc turns f into an AutoCompleter which runs asynchronously.
+func ContextFunc(f func(ctx context.Context, line []rune, pos int) ([]Candidate, int)) AutoCompleter {
+	return &dumpContextCompleter{f}
+}
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+const spinnerInterval = 100 * time.Millisecond
+
+// asyncComplete is a running ContextCompleter call.
+type asyncComplete struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// completeAsync calls c off the input loop, done gets the candidates if
+// the line didn't change in the meantime.
+func (o *opCompleter) completeAsync(c ContextCompleter, line []rune, pos int, done func([]Candidate, int)) {
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if timeout := o.op.cfg.CompleteTimeout; timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	a := &asyncComplete{cancel: cancel, done: make(chan struct{})}
+	o.op.m.Lock()
+	o.pending = a
+	o.op.m.Unlock()
+
+	result := make(chan struct{})
+	var candidates []Candidate
+	var offset int
+	go func() {
+		candidates, offset = c.DoContext(ctx, line, pos)
+		close(result)
+	}()
+
+	go func() {
+		defer close(a.done)
+		defer cancel()
+		ticker := time.NewTicker(spinnerInterval)
+		defer ticker.Stop()
+		frame := 0
+		for {
+			select {
+			case <-ticker.C:
+				o.op.m.Lock()
+				if ctx.Err() == nil {
+					o.op.buf.SetBelow(BelowComplete, spinnerFrames[frame%len(spinnerFrames)]+" completing...")
+					o.op.buf.Refresh(nil)
+				}
+				o.op.m.Unlock()
+				frame++
+			case <-ctx.Done():
+				o.op.m.Lock()
+				o.dropAsync(a)
+				o.op.m.Unlock()
+				return
+			case <-result:
+				o.op.m.Lock()
+				if ctx.Err() == nil && o.finishAsync(a) &&
+					runes.Equal(o.op.buf.Runes(), line) && o.op.buf.idx == pos {
+					done(candidates, offset)
+				}
+				o.op.m.Unlock()
+				return
+			}
+		}
+	}()
+}
+
+// finishAsync forgets a and removes its spinner, it reports whether a was
+// still the running call.
+func (o *opCompleter) finishAsync(a *asyncComplete) bool {
+	if o.pending != a {
+		return false
+	}
+	o.pending = nil
+	if !o.inCompleteMode {
+		o.op.buf.ClearBelow(BelowComplete)
+	}
+	return true
+}
+
+// CancelComplete cancels the running asynchronous completion, it reports
+// whether there was one.
+func (o *opCompleter) CancelComplete() bool {
+	o.op.m.Lock()
+	defer o.op.m.Unlock()
+	a := o.pending
+	if a == nil {
+		return false
+	}
+	a.cancel()
+	o.dropAsync(a)
+	return true
+}
+
+// dropAsync forgets a canceled call. The menu is left if it was being
+// narrowed, its candidates are stale.
+func (o *opCompleter) dropAsync(a *asyncComplete) {
+	if o.finishAsync(a) && o.inMenu() {
+		o.ExitCompleteMode(false)
+	}
+}
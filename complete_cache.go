@@ -0,0 +1,155 @@
+package readline
+
+import (
+	"sync"
+	"time"
+)
+
+// CompletionCache caches the names returned by a DynamicCompleteFunc, which
+// readline calls on every Tab and on every key typed in complete mode.
+//
+//	cache := NewCompletionCache(listBranches, time.Minute)
+//	PcItem("checkout", cache.Item(PcItem("--force")))
+//
+// Item caches the names by argument position, i.e. the words before the
+// word of the item, and by the typed prefix of that word. A longer prefix
+// reuses the names cached for a shorter one, narrowed to those which can
+// still match it. The Callback gets the line up to the end of the word.
+//
+// Complete can be passed to PcItemDynamic, it only knows the line so the
+// names are cached by the whole line and never narrowed.
+type CompletionCache struct {
+	// Callback returns the names, it's called on a cache miss
+	Callback DynamicCompleteFunc
+	// TTL is how long the names are kept, forever if zero
+	TTL time.Duration
+
+	mutex   sync.Mutex
+	entries map[cacheKey][]*cacheEntry
+	// for the tests
+	now func() time.Time
+}
+
+// cacheKey is the argument position and the words before it, or -1 and
+// the whole line.
+type cacheKey struct {
+	arg   int
+	words string
+}
+
+type cacheEntry struct {
+	prefix  []rune
+	names   []string
+	expires time.Time
+}
+
+func NewCompletionCache(callback DynamicCompleteFunc, ttl time.Duration) *CompletionCache {
+	return &CompletionCache{
+		Callback: callback,
+		TTL:      ttl,
+	}
+}
+
+func (c *CompletionCache) getNow() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// Item returns a dynamic item which completes with the cached names.
+func (c *CompletionCache) Item(pc ...PrefixCompleterInterface) *PrefixCompleter {
+	return PcItemDynamicCandidates(c.candidates, pc...)
+}
+
+func (c *CompletionCache) candidates(req *CompleteRequest) []Candidate {
+	line := req.Line[:req.Pos]
+	if words := SplitWords(line); req.Index < len(words) {
+		line = line[:words[req.Index].End]
+	}
+	key := cacheKey{arg: req.Index}
+	for _, arg := range req.Args {
+		key.words += arg + "\x00"
+	}
+	names := c.lookup(key, []rune(req.Word), true, func() []string {
+		return c.Callback(string(line))
+	})
+	candidates := make([]Candidate, len(names))
+	for idx, name := range names {
+		candidates[idx].Insert = []rune(name)
+	}
+	return candidates
+}
+
+// Complete works like Callback, it can be passed to PcItemDynamic.
+func (c *CompletionCache) Complete(line string) []string {
+	return c.lookup(cacheKey{arg: -1, words: line}, nil, false, func() []string {
+		return c.Callback(line)
+	})
+}
+
+// lookup returns the names cached for key and prefix, or those of a
+// shorter prefix narrowed to prefix if narrow is set. fetch is called on a
+// cache miss.
+func (c *CompletionCache) lookup(key cacheKey, prefix []rune, narrow bool, fetch func() []string) []string {
+	c.mutex.Lock()
+	now := c.getNow()
+	var found *cacheEntry
+	for _, e := range c.entries[key] {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			continue
+		}
+		if runes.Equal(prefix, e.prefix) {
+			found = e
+			break
+		}
+		if narrow && runes.HasPrefix(prefix, e.prefix) && (found == nil || len(e.prefix) > len(found.prefix)) {
+			found = e
+		}
+	}
+	c.mutex.Unlock()
+	if found != nil {
+		return narrowNames(found.names, prefix)
+	}
+
+	names := fetch()
+	e := &cacheEntry{prefix: runes.Copy(prefix), names: names}
+	if c.TTL > 0 {
+		e.expires = now.Add(c.TTL)
+	}
+	c.mutex.Lock()
+	if c.entries == nil {
+		c.entries = make(map[cacheKey][]*cacheEntry)
+	}
+	entries := []*cacheEntry{e}
+	for _, old := range c.entries[key] {
+		if (old.expires.IsZero() || !now.After(old.expires)) && !runes.Equal(old.prefix, e.prefix) {
+			entries = append(entries, old)
+		}
+	}
+	c.entries[key] = entries
+	c.mutex.Unlock()
+	return names
+}
+
+// narrowNames keeps the names which can still match the typed prefix, by
+// any matching mode, e.g. CompletionIgnoreCase or fuzzy matching.
+func narrowNames(names []string, prefix []rune) []string {
+	if len(prefix) == 0 {
+		return names
+	}
+	ret := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, _, ok := FuzzyMatch([]rune(name), prefix, true); ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// Invalidate drops the cached names, e.g. after a command changed them.
+func (c *CompletionCache) Invalidate() {
+	c.mutex.Lock()
+	c.entries = nil
+	c.mutex.Unlock()
+}
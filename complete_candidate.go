@@ -0,0 +1,135 @@
+package readline
+
+// Candidate is a completion candidate which can be shown differently from
+// what gets inserted, with a description.
+type Candidate struct {
+	// Insert is written at the cursor, like the suffixes returned by
+	// AutoCompleter.Do
+	Insert []rune
+	// Display is shown in the candidate list instead of the typed text
+	// followed by Insert
+	Display string
+	// Description is shown in an aligned column after the candidate
+	Description string
+	// Group is the name of the group the candidate is listed in
+	Group string
+
+	// Replace makes Insert replace the runes of the line between Start and
+	// End instead of being inserted at the cursor, e.g. when the typed text
+	// isn't a prefix of the candidate or to fix its case. The range may end
+	// after the cursor, which is moved after Insert.
+	Replace    bool
+	Start, End int
+	// Matched are the indexes of the shown runes which matched the typed
+	// text, they're highlighted in the candidate list.
+	Matched []int
+}
+
+// CandidateCompleter can be implemented by an AutoCompleter to return
+// Candidates, readline uses it instead of Do if it's available.
+type CandidateCompleter interface {
+	// DoCandidates works like AutoCompleter.Do
+	DoCandidates(line []rune, pos int) (candidates []Candidate, length int)
+}
+
+type dumpCandidateCompleter struct {
+	f func([]rune, int) ([]Candidate, int)
+}
+
+func (d *dumpCandidateCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	return d.f(line, pos)
+}
+
+func (d *dumpCandidateCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	candidates, length := d.f(line, pos)
+	return CandidateInserts(candidates), length
+}
+
+// CandidateFunc turns f into an AutoCompleter which returns Candidates.
+func CandidateFunc(f func(line []rune, pos int) ([]Candidate, int)) AutoCompleter {
+	return &dumpCandidateCompleter{f}
+}
+
+// Candidates wraps the suffixes returned by AutoCompleter.Do.
+func Candidates(newLine [][]rune) []Candidate {
+	ret := make([]Candidate, len(newLine))
+	for idx, insert := range newLine {
+		ret[idx].Insert = insert
+	}
+	return ret
+}
+
+// CandidateInserts returns the text inserted by each candidate.
+func CandidateInserts(candidates []Candidate) [][]rune {
+	ret := make([][]rune, len(candidates))
+	for idx, c := range candidates {
+		ret[idx] = c.Insert
+	}
+	return ret
+}
+
+type mergedCompleter []AutoCompleter
+
+// MergeCompleters returns an AutoCompleter which lists the candidates of
+// all the completers, in order, without duplicates.
+func MergeCompleters(completers ...AutoCompleter) AutoCompleter {
+	return mergedCompleter(completers)
+}
+
+func (m mergedCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	candidates, length := m.DoCandidates(line, pos)
+	return CandidateInserts(candidates), length
+}
+
+func (m mergedCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	return m.doMatch(line, pos, matchOptions{})
+}
+
+func (m mergedCompleter) doMatch(line []rune, pos int, opts matchOptions) ([]Candidate, int) {
+	ret := []Candidate{}
+	offset := -1
+	seen := map[string]bool{}
+	for _, c := range m {
+		candidates, length := doCandidates(c, line, pos, opts)
+		for _, candidate := range candidates {
+			// the completers may share different lengths of the line
+			if candidate.Display == "" && !candidate.Replace && length <= pos {
+				candidate.Display = string(line[pos-length:pos]) + string(candidate.Insert)
+			}
+			key := candidate.Display
+			if key == "" {
+				key = string(candidate.Insert)
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ret = append(ret, candidate)
+		}
+		if offset < 0 && len(candidates) > 0 {
+			offset = length
+		}
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return ret, offset
+}
+
+// matchCompleter is implemented by the built-in completers which honour
+// the matching options of the Config, e.g. CompletionIgnoreCase.
+type matchCompleter interface {
+	doMatch(line []rune, pos int, opts matchOptions) ([]Candidate, int)
+}
+
+// doCandidates calls the completer, through DoCandidates if it has one.
+func doCandidates(c AutoCompleter, line []rune, pos int, opts matchOptions) ([]Candidate, int) {
+	if mc, ok := c.(matchCompleter); ok {
+		return mc.doMatch(line, pos, opts)
+	}
+	if cc, ok := c.(CandidateCompleter); ok {
+		return cc.DoCandidates(line, pos)
+	}
+	newLine, length := c.Do(line, pos)
+	return Candidates(newLine), length
+}
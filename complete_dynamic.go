@@ -0,0 +1,102 @@
+package readline
+
+import (
+	"context"
+)
+
+// CompleteRequest describes the completed word to a DynamicCandidateFunc.
+type CompleteRequest struct {
+	// Context is the one passed to DoCandidatesContext, or
+	// context.Background()
+	Context context.Context
+	// Line is the whole line and Pos the cursor in it
+	Line []rune
+	Pos  int
+	// Args are the unquoted words of the line before Word
+	Args []string
+	// Word is the unquoted word the item stands for. It's the completed
+	// word, up to the cursor, if it's the last of the line before Pos.
+	// Otherwise the tree walks past the item to complete a later word,
+	// and Word is the whole typed word, e.g. "main" in "checkout main f".
+	Word string
+	// Index is the index of Word in the words of the line, len(Args)
+	Index int
+	// Path are the names of the items walked down from the root to reach
+	// the callback, e.g. ["git", "checkout"]
+	Path []string
+}
+
+// DynamicCandidateFunc returns the candidates of a PcItemDynamicCandidates,
+// their Insert is the whole word, without the trailing space. They're
+// matched with the typed word like the names of PcItem, their Display,
+// Description and Group are kept.
+type DynamicCandidateFunc func(req *CompleteRequest) []Candidate
+
+// PcItemDynamicCandidates works like PcItemDynamic, its callback gets the
+// parsed line and returns Candidates.
+func PcItemDynamicCandidates(callback DynamicCandidateFunc, pc ...PrefixCompleterInterface) *PrefixCompleter {
+	return &PrefixCompleter{
+		CandidateCallback: callback,
+		Dynamic:           true,
+		Children:          pc,
+	}
+}
+
+// DoCandidatesContext works like DoCandidates, ctx is passed to the
+// callbacks of PcItemDynamicCandidates. ContextFunc(p.DoCandidatesContext)
+// completes with p asynchronously.
+func (p *PrefixCompleter) DoCandidatesContext(ctx context.Context, line []rune, pos int) ([]Candidate, int) {
+	return p.doMatch(line, pos, matchOptions{ctx: ctx})
+}
+
+// dynamicNames returns the names of child, along with the candidates of
+// a PcItemDynamicCandidates. line is the rest of the line, up to the
+// cursor, from the word child stands for.
+func dynamicNames(child PrefixCompleterInterface, line, origLine []rune, opts matchOptions) ([][]rune, []Candidate) {
+	if pc, ok := child.(interface{ prefixCompleter() *PrefixCompleter }); ok && pc.prefixCompleter().CandidateCallback != nil {
+		rich := pc.prefixCompleter().CandidateCallback(newCompleteRequest(origLine, line, opts))
+		names := make([][]rune, len(rich))
+		for idx, c := range rich {
+			names[idx] = segmentEnd(runes.Copy(c.Insert), true)
+		}
+		return names, rich
+	}
+	if d, ok := child.(DynamicPrefixCompleterInterface); ok && d.IsDynamic() {
+		return d.GetDynamicNames(origLine), nil
+	}
+	return [][]rune{child.GetName()}, nil
+}
+
+// newCompleteRequest describes the first word of line, which ends at
+// opts.pos in origLine.
+func newCompleteRequest(origLine, line []rune, opts matchOptions) *CompleteRequest {
+	ctx := opts.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pos := opts.pos
+	if pos > len(origLine) {
+		pos = len(origLine)
+	}
+	words := SplitWords(origLine[:pos])
+	word := SplitWords(line)[0]
+	start := pos - len(line) + word.Start
+	// the word of origLine where the word of line starts
+	idx := len(words) - 1
+	for idx > 0 && words[idx].Start > start {
+		idx--
+	}
+	args := make([]string, idx)
+	for i, w := range words[:idx] {
+		args[i] = string(w.Value)
+	}
+	return &CompleteRequest{
+		Context: ctx,
+		Line:    origLine,
+		Pos:     pos,
+		Args:    args,
+		Word:    string(word.Value),
+		Index:   idx,
+		Path:    opts.path,
+	}
+}
@@ -0,0 +1,222 @@
+package readline
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EnvCompleter completes the names of the environment variables after the
+// last '$' or "${" of the last segment, e.g. "$HO" to "$HOME" and "${HO"
+// to "${HOME}". It's a SegmentCompleter which can be used alone.
+type EnvCompleter struct {
+	// Environ returns the variables as "key=value", os.Environ if nil
+	Environ func() []string
+}
+
+func (e *EnvCompleter) segmentComplete() *SegmentComplete {
+	return &SegmentComplete{SegmentCompleter: e, raw: true}
+}
+
+func (e *EnvCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	return e.segmentComplete().Do(line, pos)
+}
+
+func (e *EnvCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	return e.segmentComplete().DoCandidates(line, pos)
+}
+
+func (e *EnvCompleter) doMatch(line []rune, pos int, opts matchOptions) ([]Candidate, int) {
+	return e.segmentComplete().doMatch(line, pos, opts)
+}
+
+// DoSegment lists the last segment completed with the variable names.
+func (e *EnvCompleter) DoSegment(segment [][]rune, n int) [][]rune {
+	last := string(segment[len(segment)-1])
+	idx := strings.LastIndex(last, "$")
+	if idx < 0 {
+		return nil
+	}
+	prefix, name := last[:idx+1], last[idx+1:]
+	suffix := ""
+	if strings.HasPrefix(name, "{") {
+		prefix, name, suffix = prefix+"{", name[1:], "}"
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return !isEnvNameRune(r) }) >= 0 {
+		return nil
+	}
+
+	environ := os.Environ
+	if e.Environ != nil {
+		environ = e.Environ
+	}
+	names := []string{}
+	for _, kv := range environ() {
+		key := kv
+		if idx := strings.Index(kv, "="); idx > 0 {
+			key = kv[:idx]
+		}
+		if strings.HasPrefix(key, name) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	ret := make([][]rune, len(names))
+	for idx, key := range names {
+		ret[idx] = []rune(prefix + key + suffix)
+	}
+	return ret
+}
+
+func isEnvNameRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// UserCompleter completes "~user" with the users of the local passwd
+// database, e.g. "~ro" to "~root/". It's a SegmentCompleter which can be
+// used alone.
+//
+// On unix it reads /etc/passwd, so the users of NSS modules (e.g. LDAP) and
+// of macOS's directory services aren't listed, the current user is added.
+// Elsewhere only the current user is listed.
+type UserCompleter struct {
+	// PasswdFile is read for the users instead, it's in the format of
+	// /etc/passwd
+	PasswdFile string
+}
+
+func (u *UserCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	return SegmentAutoComplete(u).Do(line, pos)
+}
+
+func (u *UserCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	return SegmentAutoComplete(u).DoCandidates(line, pos)
+}
+
+func (u *UserCompleter) doMatch(line []rune, pos int, opts matchOptions) ([]Candidate, int) {
+	return SegmentAutoComplete(u).doMatch(line, pos, opts)
+}
+
+// continueDirs makes the SegmentComplete continue "~user/".
+func (u *UserCompleter) continueDirs() {}
+
+// DoSegment lists the users whose name starts like the last segment.
+func (u *UserCompleter) DoSegment(segment [][]rune, n int) [][]rune {
+	last := string(segment[len(segment)-1])
+	if !strings.HasPrefix(last, "~") || strings.Contains(last, "/") {
+		return nil
+	}
+	var names []string
+	if u.PasswdFile != "" {
+		names = readPasswd(u.PasswdFile)
+	} else {
+		names = userNames()
+	}
+
+	ret := [][]rune{}
+	for _, name := range names {
+		if strings.HasPrefix(name, last[1:]) {
+			ret = append(ret, []rune("~"+name+"/"))
+		}
+	}
+	return ret
+}
+
+// readPasswd returns the names of the users of a passwd file.
+func readPasswd(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var names []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		names = append(names, strings.SplitN(line, ":", 2)[0])
+	}
+	return names
+}
+
+// currentUserName returns the name of the current user, without the domain
+// on windows.
+func currentUserName() (string, bool) {
+	u, err := user.Current()
+	if err != nil || u.Username == "" {
+		return "", false
+	}
+	name := u.Username
+	if idx := strings.LastIndex(name, "\\"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return name, true
+}
+
+// expandWord replaces the word under the cursor with its expansion, see
+// Config.ExpandOnTab.
+func (o *opCompleter) expandWord() bool {
+	buf := o.op.buf
+	rs, idx := buf.Runes(), buf.idx
+	words := SplitWords(rs[:idx])
+	word := words[len(words)-1]
+	end := wordEnd(rs, idx)
+	raw := string(rs[word.Start:end])
+	// quotes and escapes turn off the expansion
+	if raw == "" || strings.ContainsAny(raw, "\\'\"") {
+		return false
+	}
+	expanded, ok := expandWord(raw)
+	if !ok {
+		return false
+	}
+	for idx := range expanded {
+		expanded[idx] = quoteWord(expanded[idx], 0)
+	}
+	buf.ReplaceRange(word.Start, end, []rune(strings.Join(expanded, " ")))
+	return true
+}
+
+// expandWord expands the leading '~', the variables and the glob pattern
+// of word like a shell. A pattern without a match is kept, a word with an
+// unset variable isn't expanded so it can be completed.
+func expandWord(word string) ([]string, bool) {
+	s := word
+	if strings.HasPrefix(s, "~") {
+		name, rest := s[1:], ""
+		if idx := strings.Index(name, "/"); idx >= 0 {
+			name, rest = name[:idx], name[idx:]
+		}
+		if name == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				s = home + rest
+			}
+		} else if u, err := user.Lookup(name); err == nil {
+			s = u.HomeDir + rest
+		}
+	}
+	unset := false
+	s = os.Expand(s, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		unset = unset || !ok
+		return value
+	})
+	if unset {
+		return nil, false
+	}
+	if strings.ContainsAny(s, "*?[") {
+		if matches, err := filepath.Glob(s); err == nil && len(matches) > 0 {
+			return matches, true
+		}
+	}
+	if s == word {
+		return nil, false
+	}
+	return []string{s}, true
+}
@@ -0,0 +1,13 @@
+// +build !aix,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!os400,!solaris
+// +build !linux appengine
+
+package readline
+
+// userNames returns the current user, there's no passwd file to list the
+// others.
+func userNames() []string {
+	if name, ok := currentUserName(); ok {
+		return []string{name}
+	}
+	return nil
+}
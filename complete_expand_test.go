@@ -0,0 +1,114 @@
+package readline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chzyer/test"
+)
+
+func TestEnvCompleter(t *testing.T) {
+	defer test.New(t)
+
+	env := &EnvCompleter{Environ: func() []string {
+		return []string{"HOME=/home/me", "HOSTNAME=box", "PATH=/bin"}
+	}}
+	for i, r := range []struct {
+		Line    string
+		Inserts []string
+	}{
+		{"echo $HO", []string{"ME ", "STNAME "}},
+		{"echo ${HOS", []string{"TNAME} "}},
+		{"echo a=$P", []string{"ATH "}},
+		{`echo "$PA`, []string{`TH" `}},
+		{"echo $HOME/", []string{}},
+		{"echo HO", []string{}},
+	} {
+		inserts, _ := env.Do([]rune(r.Line), len(r.Line))
+		test.Equal(rs(inserts), r.Inserts, fmt.Errorf("%v", i))
+	}
+}
+
+func TestUserCompleter(t *testing.T) {
+	defer test.New(t)
+
+	f, err := ioutil.TempFile("", "readline-passwd")
+	test.Nil(err)
+	defer os.Remove(f.Name())
+	f.WriteString("# users\nroot:x:0:0::/root:/bin/sh\nrob:x:1000:1000::/home/rob:/bin/sh\n")
+	f.Close()
+
+	u := &UserCompleter{PasswdFile: f.Name()}
+	for i, r := range []struct {
+		Line    string
+		Inserts []string
+	}{
+		{"cd ~ro", []string{"ot/", "b/"}},
+		{"cd ~rob", []string{"/"}},
+		{"cd ~rob/", []string{}},
+		{"cd ro", []string{}},
+	} {
+		inserts, _ := u.Do([]rune(r.Line), len(r.Line))
+		test.Equal(rs(inserts), r.Inserts, fmt.Errorf("%v", i))
+	}
+
+	// the current user is listed without a passwd file
+	if name, ok := currentUserName(); ok {
+		segments := (&UserCompleter{}).DoSegment(sr("cd", "~"+name), 0)
+		found := false
+		for _, segment := range rs(segments) {
+			found = found || segment == "~"+name+"/"
+		}
+		test.Equal(found, true)
+	}
+}
+
+func TestCompleteExpandOnTab(t *testing.T) {
+	defer test.New(t)
+
+	dir := newTestPathTree(t)
+	defer os.RemoveAll(dir)
+	os.Setenv("READLINE_TEST_DIR", dir)
+	defer os.Unsetenv("READLINE_TEST_DIR")
+
+	for i, r := range []struct {
+		Line   string
+		Idx    int
+		Result string
+	}{
+		{"ls $READLINE_TEST_DIR/*.go", -1, "ls " +
+			filepath.Join(dir, "main.go") + " " + filepath.Join(dir, "main_test.go")},
+		{"ls $READLINE_TEST_DIR/my* x", 20, "ls " + quoteWord(filepath.Join(dir, "my dir"), 0) + " x"},
+		{"cat ${READLINE_TEST_DIR}/src/lib.go", -1, "cat " + filepath.Join(dir, "src/lib.go")},
+		// nothing to expand, it's completed
+		{"ls ma", -1, "ls main"},
+		{"ls '$READLINE_TEST_DIR", -1, "ls '$READLINE_TEST_DIR"},
+		{"ls *.nothing", -1, "ls *.nothing"},
+	} {
+		op, _ := newTestOperation(&Config{
+			Prompt:       "> ",
+			ExpandOnTab:  true,
+			AutoComplete: SegmentFunc(func([][]rune, int) [][]rune { return sr("main", "main.go") }),
+		}, 80)
+		idx := r.Idx
+		if idx < 0 {
+			idx = len(r.Line)
+		}
+		op.buf.SetWithIdx(idx, []rune(r.Line))
+		op.OnComplete()
+		test.Equal(string(op.buf.Runes()), r.Result, fmt.Errorf("%v", i))
+	}
+
+	// an unset variable is completed, not erased
+	op, _ := newTestOperation(&Config{
+		Prompt:       "> ",
+		ExpandOnTab:  true,
+		AutoComplete: &EnvCompleter{},
+	}, 80)
+	op.buf.WriteString("echo $READLINE_TEST_DI")
+	op.OnComplete()
+	test.Equal(string(op.buf.Runes()), "echo $READLINE_TEST_DIR ")
+}
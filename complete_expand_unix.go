@@ -0,0 +1,17 @@
+// +build aix darwin dragonfly freebsd linux,!appengine netbsd openbsd os400 solaris
+
+package readline
+
+// userNames returns the users of /etc/passwd and the current user.
+func userNames() []string {
+	names := readPasswd("/etc/passwd")
+	if name, ok := currentUserName(); ok {
+		for _, n := range names {
+			if n == name {
+				return names
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,220 @@
+package readline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// CommandCompleter delegates the completion to an external program, like
+// bash's "complete -C". Each request is written to its stdin as a JSON
+// line and it answers with a JSON line on stdout:
+//
+//	{"line": "git ch", "pos": 6}
+//	{"candidates": [{"insert": "eckout ", "description": "switch branches"}], "length": 2}
+//
+// pos and length count runes. A candidate has the fields of Candidate in
+// lower case: insert, display, description, group, replace, start and end.
+//
+// The program runs asynchronously like a ContextCompleter, so it doesn't
+// block the typing. It's started for every request, with its stdin closed
+// after the request, unless Persistent is set.
+type CommandCompleter struct {
+	// Path and Args are the program and its arguments, see exec.Command
+	Path string
+	Args []string
+	// Env is the environment of the program, the current one if nil
+	Env []string
+	// Dir is the working directory of the program
+	Dir string
+	// Stderr receives the program's stderr, it's discarded if nil
+	Stderr io.Writer
+	// Persistent keeps the program running and sends it all the requests
+	Persistent bool
+	// Timeout bounds the requests of Do and DoCandidates, which have no
+	// context, it's 5 seconds if zero
+	Timeout time.Duration
+
+	mutex  sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+type commandRequest struct {
+	Line string `json:"line"`
+	Pos  int    `json:"pos"`
+}
+
+type commandCandidate struct {
+	Insert      string `json:"insert"`
+	Display     string `json:"display"`
+	Description string `json:"description"`
+	Group       string `json:"group"`
+	Replace     bool   `json:"replace"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+}
+
+type commandResponse struct {
+	Candidates []commandCandidate `json:"candidates"`
+	Length     int                `json:"length"`
+}
+
+func (c *CommandCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	candidates, length := c.DoCandidates(line, pos)
+	return CandidateInserts(candidates), length
+}
+
+func (c *CommandCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.DoContext(ctx, line, pos)
+}
+
+// DoContext sends the request, it returns no candidates if the program
+// fails or ctx is done before it answers.
+func (c *CommandCompleter) DoContext(ctx context.Context, line []rune, pos int) ([]Candidate, int) {
+	req, err := json.Marshal(commandRequest{Line: string(line), Pos: pos})
+	if err != nil {
+		return nil, 0
+	}
+	req = append(req, '\n')
+
+	var resp commandResponse
+	if c.Persistent {
+		err = c.requestPersistent(ctx, req, &resp)
+	} else {
+		err = c.request(ctx, req, &resp)
+	}
+	if err != nil {
+		return nil, 0
+	}
+	candidates := make([]Candidate, len(resp.Candidates))
+	for idx, cand := range resp.Candidates {
+		candidates[idx] = Candidate{
+			Insert:      []rune(cand.Insert),
+			Display:     cand.Display,
+			Description: cand.Description,
+			Group:       cand.Group,
+			Replace:     cand.Replace,
+			Start:       cand.Start,
+			End:         cand.End,
+		}
+	}
+	return candidates, resp.Length
+}
+
+func (c *CommandCompleter) command(ctx context.Context) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	cmd.Env = c.Env
+	cmd.Dir = c.Dir
+	cmd.Stderr = c.Stderr
+	return cmd
+}
+
+func (c *CommandCompleter) request(ctx context.Context, req []byte, resp *commandResponse) error {
+	cmd := c.command(ctx)
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		w.Write(req)
+		w.Close()
+	}()
+	// the process is killed if ctx is done, which ends the read
+	err = readResponse(bufio.NewReader(r), resp)
+	// it's done once it answered, it may not exit by itself. The
+	// candidates don't depend on the exit status.
+	cmd.Process.Kill()
+	cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func readResponse(r *bufio.Reader, resp *commandResponse) error {
+	line, err := r.ReadBytes('\n')
+	if len(line) == 0 {
+		return err
+	}
+	return json.Unmarshal(line, resp)
+}
+
+func (c *CommandCompleter) requestPersistent(ctx context.Context, req []byte, resp *commandResponse) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cmd == nil {
+		// not bound to ctx, it outlives the request
+		cmd := c.command(context.Background())
+		w, err := cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+		r, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		c.cmd, c.stdin, c.stdout = cmd, w, bufio.NewReader(r)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		if _, err := c.stdin.Write(req); err != nil {
+			done <- err
+			return
+		}
+		done <- readResponse(c.stdout, resp)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// the answer would be read by the next request, start over
+		c.cmd.Process.Kill()
+		<-done
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.cmd.Process.Kill()
+		c.closeLocked()
+	}
+	return err
+}
+
+// Close stops the program started by a Persistent CommandCompleter.
+func (c *CommandCompleter) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closeLocked()
+}
+
+func (c *CommandCompleter) closeLocked() error {
+	if c.cmd == nil {
+		return nil
+	}
+	c.stdin.Close()
+	err := c.cmd.Wait()
+	c.cmd, c.stdin, c.stdout = nil, nil, nil
+	return err
+}
@@ -0,0 +1,62 @@
+package readline
+
+import (
+	"sort"
+)
+
+// candidateGroup is a run of candidates of the same group.
+type candidateGroup struct {
+	name       string
+	start, end int
+}
+
+// candidateGroups splits the candidates sorted by sortGroups.
+func candidateGroups(candidates []Candidate) []candidateGroup {
+	groups := []candidateGroup{}
+	for idx, c := range candidates {
+		if len(groups) == 0 || groups[len(groups)-1].name != c.Group {
+			groups = append(groups, candidateGroup{name: c.Group, start: idx})
+		}
+		groups[len(groups)-1].end = idx + 1
+	}
+	return groups
+}
+
+// sortGroups returns the candidates with those of a group together, the
+// groups in order first and the others in the order they appear. The
+// candidates without a group come first, the order within a group is kept.
+func sortGroups(candidates []Candidate, order []string) []Candidate {
+	if len(candidateGroups(candidates)) < 2 {
+		return candidates
+	}
+	candidates = append([]Candidate(nil), candidates...)
+	rank := map[string]int{"": -1}
+	for idx, name := range order {
+		if _, ok := rank[name]; !ok {
+			rank[name] = idx
+		}
+	}
+	for _, c := range candidates {
+		if _, ok := rank[c.Group]; !ok {
+			rank[c.Group] = len(rank) + len(order)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return rank[candidates[i].Group] < rank[candidates[j].Group]
+	})
+	return candidates
+}
+
+// groupOf returns the group of a child which has one.
+func groupOf(child PrefixCompleterInterface) string {
+	if g, ok := child.(interface{ GetGroup() string }); ok {
+		return g.GetGroup()
+	}
+	return ""
+}
+
+// with returns a copy of the candidate which inserts insert.
+func (c Candidate) with(insert []rune) Candidate {
+	c.Insert = insert
+	return c
+}
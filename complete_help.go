@@ -0,0 +1,68 @@
+package readline
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ShowHelp prints the candidates of the word under the cursor with their
+// descriptions above the prompt, like the '?' of network device CLIs. It
+// returns false if the cursor is inside quotes, the key is inserted then.
+// A ContextCompleter runs asynchronously like on Tab.
+func (o *opCompleter) ShowHelp() bool {
+	buf := o.op.buf
+	rs, idx := buf.Runes(), buf.idx
+	words := SplitWords(rs[:idx])
+	if words[len(words)-1].Quote != 0 {
+		return false
+	}
+	o.ExitCompleteMode(false)
+
+	var candidates []Candidate
+	offset := 0
+	if c, ok := o.op.cfg.AutoComplete.(ContextCompleter); ok {
+		o.completeAsync(c, rs, idx, func(candidates []Candidate, offset int) {
+			o.printHelp(rs, idx, candidates, offset)
+		})
+		return true
+	}
+	if o.op.cfg.AutoComplete != nil {
+		candidates, offset = doCandidates(o.op.cfg.AutoComplete, rs, idx, o.matchOptions())
+	}
+	o.printHelp(rs, idx, candidates, offset)
+	return true
+}
+
+// printHelp prints the candidates of the word before idx in rs.
+func (o *opCompleter) printHelp(rs []rune, idx int, candidates []Candidate, offset int) {
+	if offset > idx {
+		offset = idx
+	}
+	same := rs[idx-offset : idx]
+
+	out := bytes.NewBuffer(nil)
+	if len(candidates) == 0 {
+		out.WriteString("% no candidates\n")
+	}
+	displays := make([]string, len(candidates))
+	widths := make([]int, len(candidates))
+	colWidth := 0
+	for i := range candidates {
+		displays[i] = strings.TrimRight(o.candidateDisplay(&candidates[i], same), " ")
+		widths[i] = runes.WidthAll(runes.ColorFilter([]rune(displays[i])))
+		if widths[i] > colWidth {
+			colWidth = widths[i]
+		}
+	}
+	for i, c := range candidates {
+		out.WriteString("  " + displays[i])
+		if c.Description != "" {
+			out.Write(bytes.Repeat([]byte(" "), colWidth+2-widths[i]))
+			out.WriteString(c.Description)
+		}
+		out.WriteString("\n")
+	}
+	o.op.buf.Refresh(func() {
+		o.w.Write(out.Bytes())
+	})
+}
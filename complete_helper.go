@@ -2,6 +2,7 @@ package readline
 
 import (
 	"bytes"
+	"context"
 	"strings"
 )
 
@@ -27,6 +28,19 @@ type PrefixCompleter struct {
 	Dynamic  bool
 	Callback DynamicCompleteFunc
 	Children []PrefixCompleterInterface
+	// Flags can be used anywhere after the name, until a "--"
+	Flags []*Flag
+	// Args complete the positional arguments after the name which aren't
+	// one of the Children
+	Args []*Arg
+	// Fuzzy also completes names which contain the typed runes in order,
+	// e.g. "gco" completes "git-checkout". It's only read on the root.
+	Fuzzy bool
+	// CandidateCallback is used instead of Callback by the items created
+	// by PcItemDynamicCandidates
+	CandidateCallback DynamicCandidateFunc
+	// Group is the Candidate.Group of the names, e.g. "commands"
+	Group string
 }
 
 func (p *PrefixCompleter) Tree(prefix string) string {
@@ -65,12 +79,28 @@ func (p *PrefixCompleter) GetName() []rune {
 
 func (p *PrefixCompleter) GetDynamicNames(line []rune) [][]rune {
 	var names = [][]rune{}
+	if p.CandidateCallback != nil {
+		// without the walk, the last word is the one of p
+		for _, c := range p.CandidateCallback(newCompleteRequest(line, line, matchOptions{pos: len(line)})) {
+			names = append(names, segmentEnd(runes.Copy(c.Insert), true))
+		}
+		return names
+	}
 	for _, name := range p.Callback(string(line)) {
 		names = append(names, []rune(name+" "))
 	}
 	return names
 }
 
+func (p *PrefixCompleter) GetGroup() string {
+	return p.Group
+}
+
+// prefixCompleter returns p, also for the types which embed it.
+func (p *PrefixCompleter) prefixCompleter() *PrefixCompleter {
+	return p
+}
+
 func (p *PrefixCompleter) GetChildren() []PrefixCompleterInterface {
 	return p.Children
 }
@@ -104,47 +134,185 @@ func (p *PrefixCompleter) Do(line []rune, pos int) (newLine [][]rune, offset int
 	return doInternal(p, line, pos, line)
 }
 
+// DoCandidates works like Do, it also matches the names fuzzily if
+// p.Fuzzy is set, the best matches come first.
+func (p *PrefixCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	return p.doMatch(line, pos, matchOptions{})
+}
+
+func (p *PrefixCompleter) doMatch(line []rune, pos int, opts matchOptions) ([]Candidate, int) {
+	opts.fuzzy = opts.fuzzy || p.Fuzzy
+	opts.pos = pos
+	candidates, offset := doCandidatesInternal(p, line, pos, line, opts)
+	end := wordEnd(line, pos)
+	for idx := range candidates {
+		candidates[idx].Start += pos
+		// replace the rest of the word after the cursor too
+		candidates[idx].End = end
+	}
+	return candidates, offset
+}
+
 func Do(p PrefixCompleterInterface, line []rune, pos int) (newLine [][]rune, offset int) {
 	return doInternal(p, line, pos, line)
 }
 
 func doInternal(p PrefixCompleterInterface, line []rune, pos int, origLine []rune) (newLine [][]rune, offset int) {
+	candidates, offset := doCandidatesInternal(p, line, pos, origLine, matchOptions{pos: pos})
+	return CandidateInserts(candidates), offset
+}
+
+// describe returns the help of a child which has one, e.g. a Command.
+func describe(child PrefixCompleterInterface) string {
+	if h, ok := child.(interface{ GetHelp() string }); ok {
+		return h.GetHelp()
+	}
+	return ""
+}
+
+// matchOptions are passed down the tree by doCandidatesInternal.
+type matchOptions struct {
+	fuzzy bool
+	// ignore the case, Config.CompletionIgnoreCase
+	fold bool
+	// the persistent flags of the parents
+	flags []*Flag
+	// the history of the Operation, for HistoryCompleter
+	history *opHistory
+	// for the CompleteRequest of PcItemDynamicCandidates: the cursor in
+	// the whole line, the names of the nodes walked down and the context
+	pos  int
+	path []string
+	ctx  context.Context
+}
+
+// walk returns the options for the children of the node named name.
+func (o matchOptions) walk(name []rune) matchOptions {
+	name = runes.TrimSpaceLeft(name)
+	if n := len(name); n > 0 && name[n-1] == ' ' {
+		name = name[:n-1]
+	}
+	o.path = append(o.path[:len(o.path):len(o.path)], string(name))
+	return o
+}
+
+func (o matchOptions) hasPrefix(r, prefix []rune) bool {
+	if o.fold {
+		return runes.HasPrefixFold(r, prefix)
+	}
+	return runes.HasPrefix(r, prefix)
+}
+
+// doCandidatesInternal walks down the tree, the Start and End of the
+// returned candidates are relative to pos.
+func doCandidatesInternal(p PrefixCompleterInterface, line []rune, pos int, origLine []rune, opts matchOptions) (candidates []Candidate, offset int) {
 	line = runes.TrimSpaceLeft(line[:pos])
+	if pc, ok := p.(interface{ prefixCompleter() *PrefixCompleter }); ok {
+		if pc := pc.prefixCompleter(); pc.hasSpec(opts) {
+			return pc.doSpec(line, origLine, opts)
+		}
+	}
 	goNext := false
 	var lineCompleter PrefixCompleterInterface
+	var lineName []rune
+	var scores []int
+	// the first word is quoted or has escapes
+	words := SplitWords(line)
+	quoted := !runes.Equal(words[0].Value, line[words[0].Start:words[0].End])
 	for _, child := range p.GetChildren() {
-		childNames := make([][]rune, 1)
-
-		childDynamic, ok := child.(DynamicPrefixCompleterInterface)
-		if ok && childDynamic.IsDynamic() {
-			childNames = childDynamic.GetDynamicNames(origLine)
-		} else {
-			childNames[0] = child.GetName()
-		}
+		// the candidates of PcItemDynamicCandidates, by name
+		childNames, rich := dynamicNames(child, line, origLine, opts)
 
-		for _, childName := range childNames {
-			if len(line) >= len(childName) {
-				if runes.HasPrefix(line, childName) {
-					if len(line) == len(childName) {
-						newLine = append(newLine, []rune{' '})
-					} else {
-						newLine = append(newLine, childName)
-					}
-					offset = len(childName)
-					lineCompleter = child
-					goNext = true
+		for nameIdx, childName := range childNames {
+			// the same in every branch, so the candidate keeps its group
+			meta := Candidate{Description: describe(child), Group: groupOf(child)}
+			if rich != nil {
+				meta.Display = rich[nameIdx].Display
+				meta.Description = rich[nameIdx].Description
+				if rich[nameIdx].Group != "" {
+					meta.Group = rich[nameIdx].Group
 				}
-			} else {
-				if runes.HasPrefix(childName, line) {
-					newLine = append(newLine, childName[len(line):])
+			}
+			if len(line) >= len(childName) && opts.hasPrefix(line, childName) {
+				if len(line) == len(childName) {
+					candidates = append(candidates, meta.with([]rune{' '}))
+				} else {
+					candidates = append(candidates, meta.with(childName))
+				}
+				scores = append(scores, 0)
+				offset = len(childName)
+				lineCompleter, lineName = child, childName
+				goNext = true
+				continue
+			}
+			if quoted && len(line) < len(childName) && opts.hasPrefix(childName, line) {
+				// e.g. the dynamic names of a PathCompleter are quoted
+				candidates = append(candidates, meta.with(childName[len(line):]))
+				scores = append(scores, 0)
+				offset = len(line)
+				lineCompleter, lineName = child, childName
+				continue
+			}
+			if quoted {
+				// compare the name with the unquoted word
+				name := []rune(strings.TrimSuffix(string(childName), " "))
+				if len(words) > 1 {
+					if len(words[0].Value) == len(name) && opts.hasPrefix(name, words[0].Value) && len(name) < len(childName) {
+						candidates = append(candidates, meta.with(childName))
+						scores = append(scores, 0)
+						offset = words[0].End
+						lineCompleter, lineName = child, childName
+						goNext = true
+					}
+				} else if opts.hasPrefix(childName, words[0].Value) {
+					candidates = append(candidates, meta.with(
+						quoteCompletion(childName[len(words[0].Value):], words[0].Quote),
+					))
+					scores = append(scores, 0)
 					offset = len(line)
-					lineCompleter = child
+					lineCompleter, lineName = child, childName
 				}
+				continue
+			}
+			if len(line) >= len(childName) {
+				continue
+			}
+			if !opts.fuzzy && !opts.hasPrefix(childName, line) {
+				continue
+			}
+			if opts.fuzzy && runes.Index(' ', line) >= 0 {
+				continue
 			}
+			score, matched := 0, []int(nil)
+			if opts.fuzzy {
+				var ok bool
+				score, matched, ok = FuzzyMatch(childName, line, opts.fold)
+				if !ok {
+					continue
+				}
+			}
+			c := Candidate{Insert: childName[len(line):], Matched: matched}
+			// the name replaces the typed runes if they differ, e.g. by case
+			if opts.fold || !runes.HasPrefix(childName, line) {
+				c = Candidate{
+					Insert:  childName,
+					Replace: true,
+					Start:   -len(line),
+					Matched: matched,
+				}
+			}
+			c.Display, c.Description, c.Group = meta.Display, meta.Description, meta.Group
+			candidates = append(candidates, c)
+			scores = append(scores, score)
+			offset = len(line)
+			lineCompleter, lineName = child, childName
 		}
 	}
 
-	if len(newLine) != 1 {
+	if len(candidates) != 1 {
+		if opts.fuzzy {
+			sortCandidates(candidates, scores)
+		}
 		return
 	}
 
@@ -155,11 +323,11 @@ func doInternal(p PrefixCompleterInterface, line []rune, pos int, origLine []run
 		}
 
 		tmpLine = append(tmpLine, line[i:]...)
-		return doInternal(lineCompleter, tmpLine, len(tmpLine), origLine)
+		return doCandidatesInternal(lineCompleter, tmpLine, len(tmpLine), origLine, opts.walk(lineName))
 	}
 
 	if goNext {
-		return doInternal(lineCompleter, nil, 0, origLine)
+		return doCandidatesInternal(lineCompleter, nil, 0, origLine, opts.walk(lineName))
 	}
 	return
 }
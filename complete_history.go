@@ -0,0 +1,105 @@
+package readline
+
+import (
+	"sort"
+)
+
+// HistoryCompleter completes the word under the cursor with the words of
+// the submitted lines, like M-/ in emacs. The words used more recently
+// and more often come first. Readline passes it the history, it can be
+// merged with other completers by MergeCompleters.
+type HistoryCompleter struct {
+	// Scoped only offers the words found at the same position in the
+	// lines which start with the same command, e.g. the arguments
+	// previously used with it.
+	Scoped bool
+	// MaxLines is how many of the newest lines are searched, 1000 if zero
+	MaxLines int
+}
+
+func (h *HistoryCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	candidates, length := h.DoCandidates(line, pos)
+	return CandidateInserts(candidates), length
+}
+
+// DoCandidates returns nothing, the history is only known by readline.
+func (h *HistoryCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	return h.doMatch(line, pos, matchOptions{})
+}
+
+func (h *HistoryCompleter) doMatch(line []rune, pos int, opts matchOptions) ([]Candidate, int) {
+	if opts.history == nil {
+		return nil, 0
+	}
+	words := SplitWords(line[:pos])
+	word := words[len(words)-1]
+	argIdx := len(words) - 1
+
+	max := h.MaxLines
+	if max <= 0 {
+		max = 1000
+	}
+	type stat struct {
+		score float64
+		age   int
+	}
+	stats := map[string]*stat{}
+	names := []string{}
+	for age, hline := range opts.history.lines() {
+		if age >= max {
+			break
+		}
+		hwords := SplitWords(hline)
+		if h.Scoped {
+			if argIdx >= len(hwords) {
+				continue
+			}
+			if argIdx > 0 && string(hwords[0].Value) != string(words[0].Value) {
+				continue
+			}
+			hwords = hwords[argIdx : argIdx+1]
+		}
+		for _, w := range hwords {
+			if len(w.Value) == 0 || !opts.hasPrefix(w.Value, word.Value) ||
+				runes.Equal(w.Value, word.Value) {
+				continue
+			}
+			name := string(w.Value)
+			s, ok := stats[name]
+			if !ok {
+				s = &stat{age: age}
+				stats[name] = s
+				names = append(names, name)
+			}
+			// every use counts, the recent ones more
+			s.score += 1 + 1/float64(1+age)
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := stats[names[i]], stats[names[j]]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.age < b.age
+	})
+
+	candidates := make([]Candidate, len(names))
+	for idx, name := range names {
+		value := []rune(name)
+		if !opts.fold && runes.HasPrefix(value, word.Value) {
+			candidates[idx].Insert = quoteCompletion(append(value[len(word.Value):], ' '), word.Quote)
+			continue
+		}
+		insert := quoteCompletion(append(value, ' '), word.Quote)
+		if word.Quote != 0 {
+			insert = append([]rune{word.Quote}, insert...)
+		}
+		candidates[idx] = Candidate{
+			Insert:  insert,
+			Replace: true,
+			Start:   word.Start,
+			End:     wordEnd(line, pos),
+		}
+	}
+	return candidates, pos - word.Start
+}
@@ -0,0 +1,98 @@
+package readline
+
+import (
+	"unicode"
+)
+
+// The menu select mode (Config.MenuSelect) lists the candidates one per
+// line and previews the selected one in the buffer. The typed runes are
+// inserted before the preview and narrow the candidates.
+
+func (o *opCompleter) inMenu() bool {
+	return o.menuBase != nil
+}
+
+func (o *opCompleter) enterMenu() {
+	o.menuBase = runes.Copy(o.op.buf.Runes())
+	o.menuIdx = o.op.buf.idx
+	o.inSelectMode = true
+	o.candidateChoise = 0
+	o.previewMenu()
+}
+
+// previewMenu writes the selected candidate after the typed runes.
+func (o *opCompleter) previewMenu() {
+	o.op.buf.SetWithIdx(o.menuIdx, runes.Copy(o.menuBase))
+	if o.candidateChoise >= 0 && o.candidateChoise < len(o.candidate) {
+		o.applyCandidate(&o.candidate[o.candidateChoise])
+	}
+	o.CompleteRefresh()
+}
+
+// filterMenu completes the typed runes again, a ContextCompleter runs
+// asynchronously like on Tab.
+func (o *opCompleter) filterMenu() {
+	o.op.buf.SetWithIdx(o.menuIdx, runes.Copy(o.menuBase))
+	if c, ok := o.op.cfg.AutoComplete.(ContextCompleter); ok {
+		o.completeAsync(c, runes.Copy(o.menuBase), o.menuIdx, func(candidates []Candidate, offset int) {
+			if o.inMenu() {
+				o.narrowMenu(candidates, offset)
+			}
+		})
+		return
+	}
+	candidates, offset := doCandidates(o.op.cfg.AutoComplete, o.menuBase, o.menuIdx, o.matchOptions())
+	o.narrowMenu(candidates, offset)
+}
+
+// narrowMenu lists the candidates of the typed runes.
+func (o *opCompleter) narrowMenu(candidates []Candidate, offset int) {
+	if len(candidates) == 0 {
+		o.ExitCompleteMode(false)
+		return
+	}
+	o.candidate = sortGroups(candidates, o.op.cfg.CompletionGroupOrder)
+	o.candidateOff = offset
+	o.candidateChoise = 0
+	o.candidateRowOff = 0
+	o.previewMenu()
+}
+
+// handleMenuSelect works like HandleCompleteSelect in the menu select mode.
+func (o *opCompleter) handleMenuSelect(r rune) bool {
+	switch r {
+	case CharEnter, CharCtrlJ:
+		// accept the preview, without submitting the line
+		o.ExitCompleteMode(false)
+		return false
+	case CharBell, CharInterrupt:
+		o.op.buf.SetWithIdx(o.menuIdx, o.menuBase)
+		o.ExitCompleteMode(true)
+		return false
+	case CharTab, CharNext:
+		o.nextCandidate(1)
+		o.previewMenu()
+	case CharPrev:
+		o.nextCandidate(-1)
+		o.previewMenu()
+	case CharBackspace, CharCtrlH:
+		if o.menuIdx == 0 {
+			return true
+		}
+		o.menuBase = append(o.menuBase[:o.menuIdx-1], o.menuBase[o.menuIdx:]...)
+		o.menuIdx--
+		o.filterMenu()
+	default:
+		if !unicode.IsPrint(r) {
+			o.ExitCompleteSelectMode()
+			return false
+		}
+		base := make([]rune, 0, len(o.menuBase)+1)
+		base = append(base, o.menuBase[:o.menuIdx]...)
+		base = append(base, r)
+		o.menuBase = append(base, o.menuBase[o.menuIdx:]...)
+		o.menuIdx++
+		o.filterMenu()
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package readline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathCompleter completes file system paths, it can be used as the
+// AutoCompleter, as a child of a PrefixCompleter or as a SegmentCompleter.
+//
+// "~" and "~/" are expanded to the home directory, directories get a
+// trailing '/' and files a space. Names are backslash-escaped unless the
+// typed path opens a quote, e.g. "my\ dir/" or "'my dir/". As a
+// SegmentCompleter they're escaped if SegmentComplete.Escape is set.
+type PathCompleter struct {
+	// Dir is where relative paths start, the working directory if empty
+	Dir string
+	// ShowHidden lists the names starting with '.' even if the typed name
+	// doesn't start with one
+	ShowHidden bool
+	// DirsOnly only lists directories
+	DirsOnly bool
+	// Extensions only lists the files with one of them, e.g. ".go"
+	Extensions []string
+	// Group is the Candidate.Group of the paths, e.g. "files"
+	Group string
+
+	Children []PrefixCompleterInterface
+}
+
+// PcItemPath returns a PathCompleter which completes the paths under dir.
+func PcItemPath(dir string, pc ...PrefixCompleterInterface) *PathCompleter {
+	return &PathCompleter{Dir: dir, Children: pc}
+}
+
+func (p *PathCompleter) Print(prefix string, level int, buf *bytes.Buffer) {
+	Print(p, prefix, level, buf)
+}
+
+func (p *PathCompleter) GetName() []rune {
+	return nil
+}
+
+func (p *PathCompleter) GetChildren() []PrefixCompleterInterface {
+	return p.Children
+}
+
+func (p *PathCompleter) SetChildren(children []PrefixCompleterInterface) {
+	p.Children = children
+}
+
+func (p *PathCompleter) GetGroup() string {
+	return p.Group
+}
+
+func (p *PathCompleter) IsDynamic() bool {
+	return true
+}
+
+// GetDynamicNames returns the paths which complete the last word of line.
+func (p *PathCompleter) GetDynamicNames(line []rune) [][]rune {
+	words := SplitWords(line)
+	word := words[len(words)-1]
+	ret := [][]rune{}
+	for _, path := range p.complete(word) {
+		insert := quoteCompletion([]rune(path.rest), word.Quote)
+		ret = append(ret, append(runes.Copy(line[word.Start:]), insert...))
+	}
+	return ret
+}
+
+func (p *PathCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	candidates, length := p.DoCandidates(line, pos)
+	return CandidateInserts(candidates), length
+}
+
+func (p *PathCompleter) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	words := SplitWords(line[:pos])
+	word := words[len(words)-1]
+	paths := p.complete(word)
+	ret := make([]Candidate, len(paths))
+	for idx, path := range paths {
+		ret[idx] = Candidate{
+			Insert:  quoteCompletion([]rune(path.rest), word.Quote),
+			Display: path.name,
+			Group:   p.Group,
+		}
+	}
+	return ret, pos - word.Start
+}
+
+// continueDirs makes the SegmentComplete continue the directories.
+func (p *PathCompleter) continueDirs() {}
+
+// DoSegment lists the paths which complete the last segment.
+func (p *PathCompleter) DoSegment(segment [][]rune, n int) [][]rune {
+	word := Word{Value: segment[len(segment)-1]}
+	ret := [][]rune{}
+	for _, path := range p.complete(word) {
+		cand := string(word.Value) + strings.TrimSuffix(path.rest, " ")
+		ret = append(ret, []rune(cand))
+	}
+	return ret
+}
+
+type pathCandidate struct {
+	// name is the name in its directory, with a '/' for directories
+	name string
+	// rest completes the typed word, ending with a '/' for directories
+	// and a space for files
+	rest string
+}
+
+func (p *PathCompleter) complete(word Word) []pathCandidate {
+	typed := string(word.Value)
+	if typed == "~" {
+		return []pathCandidate{{name: "~/", rest: "/"}}
+	}
+	idx := strings.LastIndex(typed, "/")
+	dir, base := typed[:idx+1], typed[idx+1:]
+
+	fsDir := dir
+	if strings.HasPrefix(fsDir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			fsDir = home + fsDir[1:]
+		}
+	}
+	fsDir = filepath.FromSlash(fsDir)
+	if !filepath.IsAbs(fsDir) {
+		fsDir = filepath.Join(p.Dir, fsDir)
+	}
+	if fsDir == "" {
+		fsDir = "."
+	}
+	infos, err := ioutil.ReadDir(fsDir)
+	if err != nil {
+		return nil
+	}
+
+	ret := []pathCandidate{}
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") && !p.ShowHidden {
+			continue
+		}
+		isDir := info.IsDir()
+		if !isDir && info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(filepath.Join(fsDir, name)); err == nil {
+				isDir = target.IsDir()
+			}
+		}
+		if !isDir && !p.acceptFile(name) {
+			continue
+		}
+
+		rest := name[len(base):] + " "
+		if isDir {
+			name += "/"
+			rest = rest[:len(rest)-1] + "/"
+		}
+		ret = append(ret, pathCandidate{name: name, rest: rest})
+	}
+	return ret
+}
+
+func (p *PathCompleter) acceptFile(name string) bool {
+	if p.DirsOnly {
+		return false
+	}
+	if len(p.Extensions) == 0 {
+		return true
+	}
+	for _, ext := range p.Extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
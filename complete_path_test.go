@@ -0,0 +1,79 @@
+package readline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chzyer/test"
+)
+
+func newTestPathTree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "readline-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"main.go", "main_test.go", "README.md", ".hidden",
+		"my dir/a.txt", "src/lib.go", "it's",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPathCompleter(t *testing.T) {
+	defer test.New(t)
+
+	dir := newTestPathTree(t)
+	defer os.RemoveAll(dir)
+
+	ret := []struct {
+		Line    string
+		Ext     []string
+		Inserts []string
+	}{
+		{"cat ma", nil, []string{"in.go ", "in_test.go "}},
+		{"cat ", []string{".go"}, []string{"main.go ", "main_test.go ", "my\\ dir/", "src/"}},
+		{"cat .h", nil, []string{"idden "}},
+		{"cat s", nil, []string{"rc/"}},
+		{"cat src/", nil, []string{"lib.go "}},
+		{"cat my", nil, []string{"\\ dir/"}},
+		{"cat my\\ dir/", nil, []string{"a.txt "}},
+		{"cat 'my", nil, []string{" dir/"}},
+		{"cat 'my dir/a", nil, []string{".txt' "}},
+		{"cat \"my dir/", nil, []string{"a.txt\" "}},
+		{"cat it", nil, []string{"\\'s "}},
+		{"cat 'it", nil, []string{"'\\''s' "}},
+		{"cat " + filepath.ToSlash(dir) + "/R", nil, []string{"EADME.md "}},
+		{"cat nothing/", nil, []string{}},
+		{"cat ~", nil, []string{"/"}},
+	}
+	for i, r := range ret {
+		p := &PathCompleter{Dir: dir, Extensions: r.Ext}
+		newLine, length := p.Do([]rune(r.Line), len([]rune(r.Line)))
+		test.Equal(rs(newLine), r.Inserts, fmt.Errorf("%v", i))
+		words := SplitWords([]rune(r.Line))
+		test.Equal(length, len([]rune(r.Line))-words[len(words)-1].Start, fmt.Errorf("%v", i))
+	}
+
+	// as a child of a PrefixCompleter
+	pc := NewPrefixCompleter(PcItem("cat", PcItemPath(dir)))
+	newLine, _ := pc.Do([]rune("cat my"), 6)
+	test.Equal(rs(newLine), []string{"\\ dir/"})
+	newLine, _ = pc.Do([]rune("cat src/"), 8)
+	test.Equal(rs(newLine), []string{"lib.go "})
+
+	// as a SegmentCompleter
+	sc := SegmentAutoComplete(&PathCompleter{Dir: dir, DirsOnly: true})
+	newLine, _ = sc.Do([]rune("cd s"), 4)
+	test.Equal(rs(newLine), []string{"rc/"})
+	newLine, _ = sc.Do([]rune("cd src/"), 7)
+	test.Equal(rs(newLine), []string{})
+}
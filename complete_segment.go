@@ -25,8 +25,14 @@ func (d *dumpSegmentCompleter) DoSegment(segment [][]rune, n int) [][]rune {
 	return d.f(segment, n)
 }
 
+// dirSegmentCompleter is implemented by the SegmentCompleters whose
+// candidates ending in '/' are continued, see SegmentComplete.ContinueDirs.
+type dirSegmentCompleter interface {
+	continueDirs()
+}
+
 func SegmentFunc(f func([][]rune, int) [][]rune) AutoCompleter {
-	return &SegmentComplete{&dumpSegmentCompleter{f}}
+	return &SegmentComplete{SegmentCompleter: &dumpSegmentCompleter{f}}
 }
 
 func SegmentAutoComplete(completer SegmentCompleter) *SegmentComplete {
@@ -37,6 +43,20 @@ func SegmentAutoComplete(completer SegmentCompleter) *SegmentComplete {
 
 type SegmentComplete struct {
 	SegmentCompleter
+	// Fuzzy also completes candidates which contain the last segment's
+	// runes in order, the best matches come first.
+	Fuzzy bool
+	// Escape backslash-escapes the shell's special characters of the
+	// candidates, e.g. "My\ Documents". They're only escaped when the
+	// typed word is quoted or has escapes otherwise.
+	Escape bool
+	// ContinueDirs doesn't end the candidates ending in '/' with a space,
+	// so e.g. a directory can be completed further. It's implied for a
+	// PathCompleter and a UserCompleter.
+	ContinueDirs bool
+
+	// raw inserts the candidates without escaping them
+	raw bool
 }
 
 func RetSegment(segments [][]rune, cands [][]rune, idx int) ([][]rune, int) {
@@ -51,6 +71,8 @@ func RetSegment(segments [][]rune, cands [][]rune, idx int) ([][]rune, int) {
 	return ret, idx
 }
 
+// SplitSegment splits the line at every space, SegmentComplete uses
+// SplitWords instead so quotes and escapes are understood.
 func SplitSegment(line []rune, pos int) ([][]rune, int) {
 	segs := [][]rune{}
 	lastIdx := -1
@@ -70,13 +92,83 @@ func SplitSegment(line []rune, pos int) ([][]rune, int) {
 }
 
 func (c *SegmentComplete) Do(line []rune, pos int) (newLine [][]rune, offset int) {
+	candidates, offset := c.candidates(line, pos, matchOptions{})
+	return CandidateInserts(candidates), offset
+}
+
+// segmentEnd ends a completed segment with a space, except directories
+// which are continued if continueDirs is set.
+func segmentEnd(cand []rune, continueDirs bool) []rune {
+	if continueDirs && len(cand) > 0 && cand[len(cand)-1] == '/' {
+		return cand
+	}
+	return append(cand, ' ')
+}
+
+func (c *SegmentComplete) DoCandidates(line []rune, pos int) ([]Candidate, int) {
+	return c.doMatch(line, pos, matchOptions{})
+}
+
+func (c *SegmentComplete) doMatch(line []rune, pos int, opts matchOptions) ([]Candidate, int) {
+	opts.fuzzy = opts.fuzzy || c.Fuzzy
+	return c.candidates(line, pos, opts)
+}
 
-	segment, idx := SplitSegment(line, pos)
+// candidates splits the line with SplitWords, so the segments passed to
+// DoSegment are unquoted and the candidates are quoted like the last word
+// if it's quoted or escaped.
+func (c *SegmentComplete) candidates(line []rune, pos int, opts matchOptions) ([]Candidate, int) {
+	words := SplitWords(line[:pos])
+	segment := make([][]rune, len(words))
+	for idx, w := range words {
+		segment[idx] = w.Value
+	}
+	word := words[len(words)-1]
+
+	quote := quoteCompletion
+	escaped := word.Quote != 0 || runes.Index('\\', line[word.Start:pos]) >= 0
+	if c.raw || (!c.Escape && !escaped) {
+		quote = rawCompletion
+	}
 
-	cands := c.DoSegment(segment, idx)
-	newLine, offset = RetSegment(segment, cands, idx)
-	for idx := range newLine {
-		newLine[idx] = append(newLine[idx], ' ')
+	_, dirs := c.SegmentCompleter.(dirSegmentCompleter)
+	dirs = dirs || c.ContinueDirs
+
+	candidates := []Candidate{}
+	scores := []int{}
+	for _, cand := range c.DoSegment(segment, len(word.Value)) {
+		if !opts.fuzzy && !opts.hasPrefix(cand, word.Value) {
+			continue
+		}
+		score, matched := 0, []int(nil)
+		if opts.fuzzy {
+			var ok bool
+			score, matched, ok = FuzzyMatch(cand, word.Value, opts.fold)
+			if !ok {
+				continue
+			}
+		}
+		candidate := Candidate{Matched: matched}
+		if opts.fuzzy || opts.fold {
+			candidate.Display = string(cand)
+		}
+		if !opts.fold && runes.HasPrefix(cand, word.Value) {
+			candidate.Insert = quote(segmentEnd(runes.Copy(cand[len(word.Value):]), dirs), word.Quote)
+		} else {
+			// replace the typed word, e.g. to fix its case
+			candidate.Insert = quote(segmentEnd(runes.Copy(cand), dirs), word.Quote)
+			if word.Quote != 0 {
+				candidate.Insert = append([]rune{word.Quote}, candidate.Insert...)
+			}
+			candidate.Replace = true
+			candidate.Start = word.Start
+			candidate.End = wordEnd(line, pos)
+		}
+		candidates = append(candidates, candidate)
+		scores = append(scores, score)
+	}
+	if opts.fuzzy {
+		sortCandidates(candidates, scores)
 	}
-	return newLine, offset
+	return candidates, pos - word.Start
 }
@@ -165,3 +165,17 @@ func TestSegmentCompleter(t *testing.T) {
 		test.Equal(length, r.Share, fmt.Errorf("%v", i))
 	}
 }
+
+func TestSegmentContinueDirs(t *testing.T) {
+	defer test.New(t)
+
+	s := SegmentFunc(func([][]rune, int) [][]rune {
+		return sr("http://example.com/", "src/")
+	})
+	// the candidates are ended with a space, unless it's asked
+	newLine, _ := s.Do([]rune("open h"), 6)
+	test.Equal(rs(newLine), []string{"ttp://example.com/ "})
+	s.(*SegmentComplete).ContinueDirs = true
+	newLine, _ = s.Do([]rune("open s"), 6)
+	test.Equal(rs(newLine), []string{"rc/"})
+}
@@ -0,0 +1,224 @@
+package readline
+
+import (
+	"strings"
+)
+
+// Flag is a command line flag of a PrefixCompleter, e.g. --output=FILE
+// or -o FILE.
+type Flag struct {
+	// Long is the name used after "--", e.g. "output"
+	Long string
+	// Short is the letter used after "-", e.g. 'o'
+	Short       rune
+	Description string
+	// HasValue makes the flag take a value, as --long=value, --long value
+	// or -s value. It's implied by Values.
+	HasValue bool
+	// Values complete the value, like Children complete the names
+	Values []PrefixCompleterInterface
+	// Repeat keeps the flag in the candidates once it's used
+	Repeat bool
+	// Persistent makes the flag valid after the Children too
+	Persistent bool
+}
+
+func (f *Flag) takesValue() bool {
+	return f.HasValue || len(f.Values) > 0
+}
+
+// Arg is a positional argument of a PrefixCompleter.
+type Arg struct {
+	Name        string
+	Description string
+	// Values complete the argument, like Children complete the names
+	Values []PrefixCompleterInterface
+	// Repeat makes the argument take all the remaining words
+	Repeat bool
+}
+
+func (p *PrefixCompleter) hasSpec(opts matchOptions) bool {
+	return len(p.Flags) > 0 || len(p.Args) > 0 || len(opts.flags) > 0
+}
+
+func (p *PrefixCompleter) arg(idx int) *Arg {
+	if idx < len(p.Args) {
+		return p.Args[idx]
+	}
+	if len(p.Args) > 0 && p.Args[len(p.Args)-1].Repeat {
+		return p.Args[len(p.Args)-1]
+	}
+	return nil
+}
+
+// child returns the child named by the first word of line.
+func (p *PrefixCompleter) child(line, origLine []rune, opts matchOptions) PrefixCompleterInterface {
+	name := string(SplitWords(line)[0].Value)
+	for _, child := range p.Children {
+		childNames, _ := dynamicNames(child, line, origLine, opts)
+		for _, childName := range childNames {
+			childName := strings.TrimSuffix(string(childName), " ")
+			if childName == name || (opts.fold && strings.EqualFold(childName, name)) {
+				return child
+			}
+		}
+	}
+	return nil
+}
+
+// doSpec completes the words after the name of p with its flags, its
+// arguments and its children.
+func (p *PrefixCompleter) doSpec(line, origLine []rune, opts matchOptions) ([]Candidate, int) {
+	flags := append(append([]*Flag{}, p.Flags...), opts.flags...)
+	words := SplitWords(line)
+	used := map[*Flag]bool{}
+	// the flag which takes the next word as its value
+	var valueOf *Flag
+	dashdash := false
+	argIdx := 0
+	for _, w := range words[:len(words)-1] {
+		value := string(w.Value)
+		switch {
+		case valueOf != nil:
+			valueOf = nil
+		case !dashdash && value == "--":
+			dashdash = true
+		case !dashdash && len(value) > 1 && value[0] == '-':
+			f, hasValue := lookupFlag(flags, value, opts.fold)
+			if f == nil {
+				break
+			}
+			used[f] = true
+			if f.takesValue() && !hasValue {
+				valueOf = f
+			}
+		default:
+			if argIdx == 0 {
+				if child := p.child(line[w.Start:], origLine, opts); child != nil {
+					childOpts := opts.walk(w.Value)
+					childOpts.flags = persistentFlags(flags)
+					rest := line[w.End:]
+					return doCandidatesInternal(child, rest, len(rest), origLine, childOpts)
+				}
+			}
+			argIdx++
+		}
+	}
+
+	last := words[len(words)-1]
+	raw := line[last.Start:]
+	value := string(last.Value)
+	if valueOf != nil {
+		return doValues(valueOf.Values, raw, origLine, opts)
+	}
+	if !dashdash && len(value) > 0 && value[0] == '-' {
+		if idx := strings.Index(value, "="); idx > 0 {
+			if f, _ := lookupFlag(flags, value[:idx], opts.fold); f != nil && f.takesValue() {
+				raw = raw[runes.Index('=', raw)+1:]
+				return doValues(f.Values, raw, origLine, opts)
+			}
+			return nil, 0
+		}
+		return completeFlags(flags, used, value, len(raw), opts), len(raw)
+	}
+
+	var slots []PrefixCompleterInterface
+	if argIdx == 0 {
+		slots = append(slots, p.Children...)
+	}
+	if arg := p.arg(argIdx); arg != nil {
+		slots = append(slots, arg.Values...)
+	}
+	candidates, offset := doValues(slots, raw, origLine, opts)
+	if len(candidates) == 0 && value == "" && !dashdash {
+		return completeFlags(flags, used, value, 0, opts), 0
+	}
+	return candidates, offset
+}
+
+// doValues completes the word with the names of values.
+func doValues(values []PrefixCompleterInterface, word, origLine []rune, opts matchOptions) ([]Candidate, int) {
+	if len(values) == 0 {
+		return nil, 0
+	}
+	p := &PrefixCompleter{Children: values}
+	opts.flags = nil
+	return doCandidatesInternal(p, word, len(word), origLine, opts)
+}
+
+// lookupFlag returns the flag used by word, and whether word has its value
+// as in --long=value. If fold is set the case is ignored, unless a flag
+// matches exactly.
+func lookupFlag(flags []*Flag, word string, fold bool) (*Flag, bool) {
+	if f, hasValue := matchFlag(flags, word, false); f != nil || !fold {
+		return f, hasValue
+	}
+	return matchFlag(flags, word, true)
+}
+
+func matchFlag(flags []*Flag, word string, fold bool) (*Flag, bool) {
+	equal := func(a, b string) bool {
+		return a == b || (fold && strings.EqualFold(a, b))
+	}
+	hasValue := false
+	for _, f := range flags {
+		if strings.HasPrefix(word, "--") {
+			name := word[2:]
+			if idx := strings.Index(name, "="); idx >= 0 {
+				name, hasValue = name[:idx], true
+			}
+			if f.Long != "" && equal(f.Long, name) {
+				return f, hasValue
+			}
+		} else if f.Short != 0 && equal(word, "-"+string(f.Short)) {
+			return f, false
+		}
+	}
+	return nil, false
+}
+
+func persistentFlags(flags []*Flag) []*Flag {
+	ret := []*Flag{}
+	for _, f := range flags {
+		if f.Persistent {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// completeFlags returns the flags starting with prefix which can still be
+// used, long flags which take a value are completed up to the '='. length
+// is how many runes were typed for prefix.
+func completeFlags(flags []*Flag, used map[*Flag]bool, prefix string, length int, opts matchOptions) []Candidate {
+	ret := []Candidate{}
+	add := func(f *Flag, name, end string) {
+		if !opts.hasPrefix([]rune(name), []rune(prefix)) {
+			return
+		}
+		c := Candidate{Description: f.Description, Group: "options"}
+		if opts.fold || !strings.HasPrefix(name, prefix) {
+			// the flag replaces the typed text, e.g. to fix its case
+			c.Insert, c.Replace, c.Start = []rune(name+end), true, -length
+		} else {
+			c.Insert = []rune(name[len(prefix):] + end)
+		}
+		ret = append(ret, c)
+	}
+	for _, f := range flags {
+		if used[f] && !f.Repeat {
+			continue
+		}
+		if f.Long != "" {
+			end := " "
+			if f.takesValue() {
+				end = "="
+			}
+			add(f, "--"+f.Long, end)
+		}
+		if f.Short != 0 {
+			add(f, "-"+string(f.Short), " ")
+		}
+	}
+	return ret
+}
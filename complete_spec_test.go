@@ -0,0 +1,54 @@
+package readline
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chzyer/test"
+)
+
+func TestPrefixCompleterSpec(t *testing.T) {
+	defer test.New(t)
+
+	build := PcItem("build")
+	build.Flags = []*Flag{
+		{Long: "output", Short: 'o', Values: []PrefixCompleterInterface{PcItem("bin"), PcItem("out")}},
+		{Long: "race"},
+		{Long: "tags", HasValue: true, Repeat: true},
+	}
+	build.Args = []*Arg{
+		{Name: "package", Values: []PrefixCompleterInterface{PcItem("./..."), PcItem("./cmd")}, Repeat: true},
+	}
+	run := PcItem("run")
+	run.Args = []*Arg{
+		{Name: "file", Values: []PrefixCompleterInterface{PcItem("main.go")}},
+		{Name: "arg", Values: []PrefixCompleterInterface{PcItem("first")}},
+	}
+	root := NewPrefixCompleter(PcItem("go", build, run))
+	root.Flags = []*Flag{{Long: "verbose", Short: 'v', Persistent: true}}
+
+	ret := []struct {
+		Line    string
+		Inserts []string
+	}{
+		{"go build -", []string{"-output=", "o ", "-race ", "-tags=", "-verbose ", "v "}},
+		{"go build --r", []string{"ace "}},
+		{"go build --race --", []string{"output=", "tags=", "verbose "}},
+		{"go build --tags=x --t", []string{"ags="}},
+		{"go build --output=", []string{"bin ", "out "}},
+		{"go build --output=o", []string{"ut "}},
+		{"go build -o ", []string{"bin ", "out "}},
+		{"go build -o bin ", []string{"./... ", "./cmd "}},
+		{"go build ./cmd ./c", []string{"md "}},
+		{"go build ./cmd -- -", []string{}},
+		{"go build --tags=", []string{}},
+		{"go run ", []string{"main.go "}},
+		{"go run -v main.go ", []string{"first "}},
+		{"go run main.go first ", []string{"--verbose ", "-v "}},
+		{"-v go b", []string{"uild "}},
+	}
+	for i, r := range ret {
+		newLine, _ := root.Do([]rune(r.Line), len([]rune(r.Line)))
+		test.Equal(rs(newLine), r.Inserts, fmt.Errorf("%v", i))
+	}
+}
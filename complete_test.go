@@ -0,0 +1,772 @@
+package readline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chzyer/test"
+)
+
+func newTestOperation(cfg *Config, width int) (*Operation, *bytes.Buffer) {
+	buf, w := newTestRuneBuffer(cfg, width)
+	op := &Operation{cfg: cfg, buf: buf}
+	op.opCompleter = newOpCompleter(w, op, width)
+	return op, w
+}
+
+func TestCompleteDescription(t *testing.T) {
+	defer test.New(t)
+
+	op, _ := newTestOperation(&Config{
+		Prompt: "> ",
+		AutoComplete: CandidateFunc(func(line []rune, pos int) ([]Candidate, int) {
+			return []Candidate{
+				{Insert: []rune("force "), Description: "overwrite existing files"},
+				{Insert: []rune("quiet "), Display: "--quiet[=N]", Description: "be quiet"},
+			}, 2
+		}),
+	}, 40)
+	op.buf.WriteString("--")
+	test.Equal(op.OnComplete(), true)
+	test.Equal(op.IsInCompleteMode(), true)
+	test.Equal(op.buf.below[BelowComplete], ""+
+		"--force      overwrite existing files\n"+
+		"--quiet[=N]  be quiet")
+
+	op.EnterCompleteSelectMode()
+	op.doSelect()
+	test.Equal(op.HandleCompleteSelect(CharEnter), false)
+	test.Equal(string(op.buf.Runes()), "--force ")
+	test.Equal(op.IsInCompleteMode(), false)
+}
+
+func TestCompletePaging(t *testing.T) {
+	defer test.New(t)
+
+	op, _ := newTestOperation(&Config{
+		Prompt:               "> ",
+		CompletionQueryItems: 20,
+		FuncGetHeight:        func() int { return 5 },
+		AutoComplete: CandidateFunc(func(line []rune, pos int) ([]Candidate, int) {
+			candidates := make([]Candidate, 30)
+			for idx := range candidates {
+				candidates[idx].Insert = []rune(fmt.Sprintf("%02d", idx))
+			}
+			return candidates, 0
+		}),
+	}, 10)
+	test.Equal(op.OnComplete(), true)
+	test.Equal(op.IsInCompleteQueryMode(), true)
+	test.Equal(op.buf.below[BelowComplete], "Display all 30 possibilities? (y or n)")
+
+	op.HandleCompleteQuery('n')
+	test.Equal(op.IsInCompleteMode(), false)
+	test.Equal(op.buf.below[BelowComplete], "")
+
+	op.OnComplete()
+	op.HandleCompleteQuery('y')
+	test.Equal(op.IsInCompleteQueryMode(), false)
+	// 10 rows of 3 columns, 3 rows fit above the --More--
+	rows := strings.Split(op.buf.below[BelowComplete], "\n")
+	test.Equal(len(rows), 4)
+	test.Equal(strings.Fields(rows[0]), []string{"00", "01", "02"})
+	test.Equal(rows[3], "--More-- (1-3 of 10 rows)")
+
+	// the rows scroll with the selection
+	op.EnterCompleteSelectMode()
+	op.doSelect()
+	for i := 0; i < 4; i++ {
+		op.HandleCompleteSelect(CharNext)
+	}
+	rows = strings.Split(op.buf.below[BelowComplete], "\n")
+	test.Equal(strings.Fields(rows[0])[0], "06")
+	test.Equal(rows[3], "--More-- (3-5 of 10 rows)")
+
+	// a status line leaves a row less
+	op.ExitCompleteMode(false)
+	op.buf.SetBelow(BelowStatus, "status")
+	op.OnComplete()
+	op.HandleCompleteQuery('y')
+	rows = strings.Split(op.buf.below[BelowComplete], "\n")
+	test.Equal(len(rows), 3)
+	test.Equal(rows[2], "--More-- (1-2 of 10 rows)")
+}
+
+func pendingComplete(op *Operation) *asyncComplete {
+	op.m.Lock()
+	defer op.m.Unlock()
+	return op.pending
+}
+
+func TestCompleteAsync(t *testing.T) {
+	defer test.New(t)
+
+	release := make(chan struct{})
+	canceled := make(chan struct{}, 1)
+	cfg := &Config{
+		Prompt: "> ",
+		AutoComplete: ContextFunc(func(ctx context.Context, line []rune, pos int) ([]Candidate, int) {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				canceled <- struct{}{}
+				return nil, 0
+			}
+			return []Candidate{{Insert: []rune("llo ")}}, 2
+		}),
+	}
+
+	// the result is written when it arrives
+	op, _ := newTestOperation(cfg, 80)
+	op.buf.WriteString("he")
+	test.Equal(op.OnComplete(), true)
+	test.Equal(string(op.buf.Runes()), "he")
+	pending := pendingComplete(op)
+	release <- struct{}{}
+	<-pending.done
+	test.Equal(string(op.buf.Runes()), "hello ")
+	test.Equal(op.pending == nil, true)
+
+	// stale results are dropped
+	op.buf.Set([]rune("he"))
+	op.OnComplete()
+	pending = pendingComplete(op)
+	op.buf.WriteString("x")
+	release <- struct{}{}
+	<-pending.done
+	test.Equal(string(op.buf.Runes()), "hex")
+
+	// typing cancels
+	op.OnComplete()
+	pending = pendingComplete(op)
+	test.Equal(op.CancelComplete(), true)
+	<-canceled
+	<-pending.done
+	test.Equal(op.CancelComplete(), false)
+	test.Equal(op.buf.below[BelowComplete], "")
+
+	// so does the timeout
+	cfg.CompleteTimeout = time.Millisecond
+	op.OnComplete()
+	pending = pendingComplete(op)
+	<-canceled
+	<-pending.done
+	test.Equal(op.pending == nil, true)
+	test.Equal(string(op.buf.Runes()), "hex")
+}
+
+func TestCompleteAsyncMenuHelp(t *testing.T) {
+	defer test.New(t)
+
+	release := make(chan struct{})
+	canceled := make(chan struct{}, 1)
+	names := []string{"checkout", "cherry-pick", "commit"}
+	cfg := &Config{
+		Prompt:     "> ",
+		MenuSelect: true,
+		HelpKey:    '?',
+		AutoComplete: ContextFunc(func(ctx context.Context, line []rune, pos int) ([]Candidate, int) {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				canceled <- struct{}{}
+				return nil, 0
+			}
+			candidates := []Candidate{}
+			for _, name := range names {
+				if strings.HasPrefix(name, string(line[:pos])) {
+					candidates = append(candidates, Candidate{Insert: []rune(name[pos:])})
+				}
+			}
+			return candidates, pos
+		}),
+	}
+	op, w := newTestOperation(cfg, 80)
+	op.buf.WriteString("c")
+	op.OnComplete()
+	pending := pendingComplete(op)
+	release <- struct{}{}
+	<-pending.done
+	test.Equal(op.IsInCompleteSelectMode(), true)
+
+	// narrowing the menu doesn't block the input loop
+	test.Equal(op.HandleCompleteSelect('h'), true)
+	test.Equal(string(op.buf.Runes()), "ch")
+	pending = pendingComplete(op)
+	test.Equal(pending != nil, true)
+	release <- struct{}{}
+	<-pending.done
+	test.Equal(len(op.candidate), 2)
+	test.Equal(string(op.buf.Runes()), "checkout")
+
+	// the menu is left if it's canceled
+	op.HandleCompleteSelect('e')
+	test.Equal(op.CancelComplete(), true)
+	<-canceled
+	test.Equal(op.IsInCompleteMode(), false)
+	test.Equal(string(op.buf.Runes()), "che")
+
+	// neither does the help
+	op.buf.Set([]rune("co"))
+	w.Reset()
+	test.Equal(op.ShowHelp(), true)
+	pending = pendingComplete(op)
+	test.Equal(pending != nil, true)
+	release <- struct{}{}
+	<-pending.done
+	test.Equal(strings.Contains(w.String(), "  commit\n"), true)
+
+	// and it's bounded by CompleteTimeout
+	cfg.CompleteTimeout = time.Millisecond
+	w.Reset()
+	op.ShowHelp()
+	<-canceled
+	test.Equal(strings.Contains(w.String(), "commit"), false)
+}
+
+func TestCompleteMenuSelect(t *testing.T) {
+	defer test.New(t)
+
+	op, _ := newTestOperation(&Config{
+		Prompt:     "> ",
+		MenuSelect: true,
+		AutoComplete: NewPrefixCompleter(
+			PcItem("checkout"), PcItem("cherry-pick"), PcItem("commit"),
+		),
+	}, 80)
+	op.buf.WriteString("c")
+	test.Equal(op.OnComplete(), true)
+	test.Equal(op.IsInCompleteSelectMode(), true)
+	// the first candidate is previewed, one per line
+	test.Equal(string(op.buf.Runes()), "checkout ")
+	test.Equal(strings.Fields(op.buf.below[BelowComplete]), []string{
+		"\033[30;47mcheckout", "\033[0m", "cherry-pick", "commit",
+	})
+
+	test.Equal(op.HandleCompleteSelect(CharNext), true)
+	test.Equal(string(op.buf.Runes()), "cherry-pick ")
+	test.Equal(op.HandleCompleteSelect(CharPrev), true)
+	test.Equal(op.HandleCompleteSelect(CharPrev), true)
+	test.Equal(string(op.buf.Runes()), "commit ")
+
+	// typing narrows the candidates
+	test.Equal(op.HandleCompleteSelect('h'), true)
+	test.Equal(len(op.candidate), 2)
+	test.Equal(op.HandleCompleteSelect('e'), true)
+	test.Equal(op.HandleCompleteSelect('r'), true)
+	test.Equal(string(op.buf.Runes()), "cherry-pick ")
+	test.Equal(op.HandleCompleteSelect(CharBackspace), true)
+	test.Equal(len(op.candidate), 2)
+
+	// Enter accepts the preview
+	test.Equal(op.HandleCompleteSelect(CharEnter), false)
+	test.Equal(string(op.buf.Runes()), "checkout ")
+	test.Equal(op.IsInCompleteMode(), false)
+
+	// Ctrl-G restores the typed line
+	op.buf.Set([]rune("c"))
+	op.OnComplete()
+	op.HandleCompleteSelect('o')
+	test.Equal(string(op.buf.Runes()), "commit ")
+	test.Equal(op.HandleCompleteSelect(CharBell), false)
+	test.Equal(string(op.buf.Runes()), "co")
+}
+
+func TestCompleteReplaceRange(t *testing.T) {
+	defer test.New(t)
+
+	words := []string{"hello", "help", "world"}
+	op, _ := newTestOperation(&Config{
+		Prompt: "> ",
+		// completes the word under the cursor, ignoring the case
+		AutoComplete: CandidateFunc(func(line []rune, pos int) ([]Candidate, int) {
+			w := SplitWords(line[:pos])
+			start, end := w[len(w)-1].Start, wordEnd(line, pos)
+			candidates := []Candidate{}
+			for _, word := range words {
+				if runes.HasPrefixFold([]rune(word), line[start:pos]) {
+					candidates = append(candidates, Candidate{
+						Insert: []rune(word), Replace: true, Start: start, End: end,
+					})
+				}
+			}
+			return candidates, pos - start
+		}),
+	}, 80)
+
+	// the whole word is replaced and the cursor is after it
+	op.buf.WriteString("say WORxx again")
+	op.buf.SetWithIdx(7, op.buf.Runes())
+	test.Equal(op.OnComplete(), true)
+	test.Equal(string(op.buf.Runes()), "say world again")
+	test.Equal(op.buf.Pos(), 9)
+
+	// the shared beginning fixes the case
+	op.buf.SetWithIdx(6, []rune("say HE"))
+	test.Equal(op.OnComplete(), true)
+	test.Equal(string(op.buf.Runes()), "say hel")
+	test.Equal(op.IsInCompleteMode(), false)
+	test.Equal(op.OnComplete(), true)
+	test.Equal(op.IsInCompleteMode(), true)
+	test.Equal(string(op.buf.Runes()), "say hel")
+
+	// a range out of the buffer is clipped
+	op.buf.Set([]rune("abc"))
+	op.buf.ReplaceRange(-1, 10, []rune("x"))
+	test.Equal(string(op.buf.Runes()), "x")
+	test.Equal(op.buf.Pos(), 1)
+
+	// PrefixCompleter replaces the rest of the word under the cursor
+	p := NewPrefixCompleter(PcItem("git-checkout"))
+	p.Fuzzy = true
+	candidates, _ := p.DoCandidates([]rune("gcoxyz rest"), 3)
+	test.Equal(candidates[0].Start, 0)
+	test.Equal(candidates[0].End, 6)
+}
+
+func TestCompleteIgnoreCase(t *testing.T) {
+	defer test.New(t)
+
+	tree := NewPrefixCompleter(
+		PcItem("Git", PcItem("checkout"), PcItem("Commit")),
+		PcItem("GitK"),
+	)
+	segments := SegmentFunc(func(segment [][]rune, n int) [][]rune {
+		return sr("Makefile", "main.go")
+	})
+	ls := PcItem("ls")
+	ls.Flags = []*Flag{
+		{Long: "all", Short: 'a'},
+		{Long: "color", Values: []PrefixCompleterInterface{PcItem("never"), PcItem("auto")}},
+	}
+	flags := NewPrefixCompleter(ls)
+	for i, r := range []struct {
+		Completer AutoCompleter
+		Line      string
+		Result    string
+	}{
+		// the shared beginning is written with the candidates' case
+		{tree, "gi", "Git"},
+		{tree, "GITK", "GitK "},
+		{tree, "git co", "git Commit "},
+		{tree, "git CH", "git checkout "},
+		{segments, "edit M", "edit Ma"},
+		{segments, "edit mak", "edit Makefile "},
+		{segments, `edit "mak`, `edit "Makefile" `},
+		{flags, "ls --AL", "ls --all "},
+		{flags, "ls --Co", "ls --color="},
+		{flags, "ls --COLOR=NE", "ls --COLOR=never "},
+		{flags, "ls --ALL --a", "ls --ALL --a"},
+	} {
+		op, _ := newTestOperation(&Config{
+			Prompt:               "> ",
+			AutoComplete:         r.Completer,
+			CompletionIgnoreCase: true,
+		}, 80)
+		op.buf.WriteString(r.Line)
+		op.OnComplete()
+		test.Equal(string(op.buf.Runes()), r.Result, fmt.Errorf("%v", i))
+	}
+
+	// the case matters by default
+	op, _ := newTestOperation(&Config{Prompt: "> ", AutoComplete: tree}, 80)
+	op.buf.WriteString("gi")
+	op.OnComplete()
+	test.Equal(string(op.buf.Runes()), "gi")
+}
+
+func TestCompleteHistory(t *testing.T) {
+	defer test.New(t)
+
+	cfg := &Config{Prompt: "> ", HistoryLimit: 100}
+	op, _ := newTestOperation(cfg, 80)
+	op.history = newOpHistory(cfg)
+	for _, line := range []string{
+		"ssh deploy@web1",
+		"scp build.tar deploy@web2:",
+		"ssh deploy@web2",
+		"ssh deploy@web2 uptime",
+	} {
+		op.history.New([]rune(line))
+	}
+	opts := op.matchOptions()
+
+	for i, r := range []struct {
+		Completer *HistoryCompleter
+		Line      string
+		Result    []string
+	}{
+		// the recent and frequent words come first
+		{&HistoryCompleter{}, "ssh de", []string{"ploy@web2 ", "ploy@web2: ", "ploy@web1 "}},
+		{&HistoryCompleter{}, "s", []string{"sh ", "cp "}},
+		// only the arguments used with the same command
+		{&HistoryCompleter{Scoped: true}, "ssh de", []string{"ploy@web2 ", "ploy@web1 "}},
+		{&HistoryCompleter{Scoped: true}, "ssh deploy@web2 ", []string{"uptime "}},
+		{&HistoryCompleter{Scoped: true}, "ls ", []string{}},
+	} {
+		candidates, _ := r.Completer.doMatch([]rune(r.Line), len(r.Line), opts)
+		result := []string{}
+		for _, c := range candidates {
+			result = append(result, string(c.Insert))
+		}
+		test.Equal(result, r.Result, fmt.Errorf("%v", i))
+	}
+
+	// merged with another completer, the duplicates are dropped
+	op.cfg.AutoComplete = MergeCompleters(
+		NewPrefixCompleter(PcItem("ssh", PcItem("deploy@web3"))),
+		&HistoryCompleter{Scoped: true},
+	)
+	op.buf.WriteString("ssh deploy@web")
+	test.Equal(op.OnComplete(), true)
+	test.Equal(op.IsInCompleteMode(), true)
+	test.Equal(strings.Fields(op.buf.below[BelowComplete]), []string{
+		"deploy@web3", "deploy@web2", "deploy@web1",
+	})
+
+	// without readline there is no history
+	candidates, _ := (&HistoryCompleter{}).DoCandidates([]rune("ssh "), 4)
+	test.Equal(len(candidates), 0)
+
+	// the line being edited isn't offered, e.g. when browsing
+	op.history.Prev()
+	candidates, _ = (&HistoryCompleter{Scoped: true}).doMatch([]rune("ssh deploy@web2 "), 16, opts)
+	test.Equal(len(candidates), 0)
+	// or typed in a new history
+	history := newOpHistory(cfg)
+	history.Update([]rune("ssh deploy@web1"), false)
+	opts.history = history
+	candidates, _ = (&HistoryCompleter{}).doMatch([]rune("ssh de"), 6, opts)
+	test.Equal(len(candidates), 0)
+}
+
+func TestCompletionCache(t *testing.T) {
+	defer test.New(t)
+
+	calls := 0
+	lines := []string{}
+	now := time.Unix(0, 0)
+	cache := NewCompletionCache(func(line string) []string {
+		calls++
+		lines = append(lines, line)
+		return []string{"main", "master", "dev", "fast"}
+	}, time.Minute)
+	cache.now = func() time.Time { return now }
+	tree := NewPrefixCompleter(
+		PcItem("checkout", cache.Item(PcItem("force"))),
+		PcItem("merge", cache.Item()),
+	)
+
+	for i, r := range []struct {
+		Line    string
+		Inserts []string
+		Calls   int
+	}{
+		{"checkout ", []string{"main ", "master ", "dev ", "fast "}, 1},
+		// narrowed from the cached names
+		{"checkout m", []string{"ain ", "aster "}, 1},
+		{"checkout ma", []string{"in ", "ster "}, 1},
+		{"checkout mas", []string{"ter "}, 1},
+		// the walk past the item narrows with its own word
+		{"checkout main f", []string{"orce "}, 1},
+		{"checkout main fo", []string{"rce "}, 1},
+		// another argument position
+		{"merge ", []string{"main ", "master ", "dev ", "fast "}, 2},
+	} {
+		inserts, _ := tree.Do([]rune(r.Line), len(r.Line))
+		test.Equal(rs(inserts), r.Inserts, fmt.Errorf("%v", i))
+		test.Equal(calls, r.Calls, fmt.Errorf("%v", i))
+	}
+	test.Equal(lines, []string{"checkout ", "merge "})
+
+	// the names expire
+	now = now.Add(2 * time.Minute)
+	tree.Do([]rune("checkout main f"), 15)
+	test.Equal(calls, 3)
+	test.Equal(lines[2], "checkout main")
+
+	cache.Invalidate()
+	tree.Do([]rune("checkout "), 9)
+	test.Equal(calls, 4)
+
+	// Complete only knows the line, it doesn't narrow
+	calls = 0
+	tree = NewPrefixCompleter(PcItem("checkout", PcItemDynamic(cache.Complete, PcItem("force"))))
+	for i, line := range []string{"checkout main f", "checkout main fo", "checkout main fo"} {
+		inserts, _ := tree.Do([]rune(line), len(line))
+		test.Equal(len(inserts), 1, fmt.Errorf("%v", i))
+	}
+	test.Equal(calls, 2)
+}
+
+// TestHelperProcess is the program run by the CommandCompleter tests, it
+// completes the last word with the words of its arguments. The
+// descriptions count the requests it served. It hangs on the line "hang"
+// and keeps running after answering a line starting with "linger".
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+	words := os.Args[len(os.Args)-1]
+	r := bufio.NewReader(os.Stdin)
+	for served := 1; ; served++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req commandRequest
+		json.Unmarshal(line, &req)
+		if req.Line == "hang" {
+			select {}
+		}
+		w := SplitWords([]rune(req.Line)[:req.Pos])
+		last := w[len(w)-1].Value
+		resp := commandResponse{Length: len(last)}
+		for _, word := range strings.Fields(words) {
+			if strings.HasPrefix(word, string(last)) {
+				resp.Candidates = append(resp.Candidates, commandCandidate{
+					Insert:      word[len(last):] + " ",
+					Description: fmt.Sprint(served),
+				})
+			}
+		}
+		json.NewEncoder(os.Stdout).Encode(resp)
+		if strings.HasPrefix(req.Line, "linger") {
+			select {}
+		}
+	}
+}
+
+func TestCommandCompleter(t *testing.T) {
+	defer test.New(t)
+
+	for _, persistent := range []bool{false, true} {
+		c := &CommandCompleter{
+			Path:       os.Args[0],
+			Args:       []string{"-test.run=TestHelperProcess", "--", "checkout cherry-pick commit"},
+			Env:        append(os.Environ(), "GO_WANT_HELPER_PROCESS=1"),
+			Persistent: persistent,
+		}
+		for i := 1; i <= 2; i++ {
+			candidates, length := c.DoCandidates([]rune("git ch"), 6)
+			test.Equal(length, 2)
+			test.Equal(len(candidates), 2)
+			test.Equal(string(candidates[0].Insert), "eckout ")
+			served := "1"
+			if persistent {
+				served = fmt.Sprint(i)
+			}
+			test.Equal(candidates[1].Description, served)
+		}
+
+		// a hanging program is killed when ctx is done
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		candidates, _ := c.DoContext(ctx, []rune("hang"), 4)
+		cancel()
+		test.Equal(len(candidates), 0)
+		candidates, _ = c.DoCandidates([]rune("co"), 2)
+		test.Equal(len(candidates), 1)
+		test.Nil(c.Close())
+	}
+
+	// the program is stopped once it answered
+	c := &CommandCompleter{
+		Path:    os.Args[0],
+		Args:    []string{"-test.run=TestHelperProcess", "--", "checkout cherry-pick"},
+		Env:     append(os.Environ(), "GO_WANT_HELPER_PROCESS=1"),
+		Timeout: 10 * time.Second,
+	}
+	start := time.Now()
+	candidates, _ := c.DoCandidates([]rune("linger ch"), 9)
+	test.Equal(len(candidates), 2)
+	test.Equal(time.Since(start) < 5*time.Second, true)
+
+	// through readline
+	op, _ := newTestOperation(&Config{
+		Prompt: "> ",
+		AutoComplete: &CommandCompleter{
+			Path: os.Args[0],
+			Args: []string{"-test.run=TestHelperProcess", "--", "checkout"},
+			Env:  append(os.Environ(), "GO_WANT_HELPER_PROCESS=1"),
+		},
+	}, 80)
+	op.buf.WriteString("git c")
+	test.Equal(op.OnComplete(), true)
+	<-pendingComplete(op).done
+	test.Equal(string(op.buf.Runes()), "git checkout ")
+}
+
+func TestCompleteDynamicCandidates(t *testing.T) {
+	defer test.New(t)
+
+	var req *CompleteRequest
+	branches := func(r *CompleteRequest) []Candidate {
+		req = r
+		return []Candidate{
+			{Insert: []rune("main"), Description: "default branch"},
+			{Insert: []rune("master"), Description: "old default"},
+		}
+	}
+	tree := NewPrefixCompleter(
+		PcItem("git",
+			PcItem("checkout", PcItemDynamicCandidates(branches,
+				PcItemDynamicCandidates(branches),
+			)),
+		),
+	)
+
+	candidates, length := tree.DoCandidates([]rune("git checkout ma"), 15)
+	test.Equal(length, 2)
+	test.Equal(len(candidates), 2)
+	test.Equal(string(candidates[1].Insert), "ster ")
+	test.Equal(candidates[1].Description, "old default")
+	test.Equal(req.Args, []string{"git", "checkout"})
+	test.Equal(req.Word, "ma")
+	test.Equal(req.Index, 2)
+	test.Equal(req.Pos, 15)
+	test.Equal(req.Path, []string{"git", "checkout"})
+	test.Equal(req.Context, context.Background())
+
+	// the path goes through the dynamic names
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	line := []rune(`git checkout main "m rest`)
+	tree.DoCandidatesContext(ctx, line, 20)
+	test.Equal(req.Path, []string{"git", "checkout", "main"})
+	test.Equal(req.Word, "m")
+	test.Equal(req.Index, 3)
+	test.Equal(string(req.Line), string(line))
+	test.Equal(req.Context.Value(key{}), "value")
+
+	// walking past the item, the request is about its own word
+	reqs := []*CompleteRequest{}
+	filtered := NewPrefixCompleter(PcItem("checkout", PcItemDynamicCandidates(func(r *CompleteRequest) []Candidate {
+		reqs = append(reqs, r)
+		ret := []Candidate{}
+		for _, name := range []string{"main", "master"} {
+			if strings.HasPrefix(name, r.Word) {
+				ret = append(ret, Candidate{Insert: []rune(name)})
+			}
+		}
+		return ret
+	}, PcItem("force"))))
+	candidates, _ = filtered.DoCandidates([]rune("checkout main f"), 15)
+	test.Equal(CandidateInserts(candidates), [][]rune{[]rune("orce ")})
+	test.Equal(reqs[0].Word, "main")
+	test.Equal(reqs[0].Index, 1)
+	test.Equal(reqs[0].Args, []string{"checkout"})
+	test.Equal(reqs[0].Pos, 15)
+
+	// so it is with flags
+	checkout := filtered.Children[0].(*PrefixCompleter)
+	checkout.Flags = []*Flag{{Long: "quiet"}}
+	candidates, _ = filtered.DoCandidates([]rune("checkout main f"), 15)
+	test.Equal(CandidateInserts(candidates), [][]rune{[]rune("orce ")})
+}
+
+func TestCompleteHelp(t *testing.T) {
+	defer test.New(t)
+
+	op, w := newTestOperation(&Config{
+		Prompt:  "> ",
+		HelpKey: '?',
+		AutoComplete: NewCommandTree(
+			CmdItem("show", "show the state", nil,
+				CmdItem("interfaces", "the interfaces", nil),
+				CmdItem("version", "the software version", nil),
+			),
+			CmdItem("shutdown", "", nil),
+		),
+	}, 80)
+
+	for i, r := range []struct {
+		Line   string
+		Result string
+	}{
+		{"sh", "" +
+			"  show      show the state\n" +
+			"  shutdown\n"},
+		{"show ", "" +
+			"  interfaces  the interfaces\n" +
+			"  version     the software version\n"},
+		{"show x", "% no candidates\n"},
+	} {
+		op.buf.Set([]rune(r.Line))
+		w.Reset()
+		test.Equal(op.ShowHelp(), true)
+		test.Equal(strings.Contains(w.String(), r.Result), true, fmt.Errorf("%v: %q", i, w.String()))
+		// the line is left alone
+		test.Equal(string(op.buf.Runes()), r.Line)
+	}
+
+	// the key is typed inside quotes
+	op.buf.Set([]rune(`show "a`))
+	test.Equal(op.ShowHelp(), false)
+}
+
+func TestCompleteGroups(t *testing.T) {
+	defer test.New(t)
+
+	op, _ := newTestOperation(&Config{
+		Prompt:               "> ",
+		CompletionGroupOrder: []string{"commands", "options"},
+		AutoComplete: CandidateFunc(func(line []rune, pos int) ([]Candidate, int) {
+			return []Candidate{
+				{Insert: []rune("main.go"), Group: "files"},
+				{Insert: []rune("build"), Group: "commands"},
+				{Insert: []rune("--verbose"), Group: "options"},
+				{Insert: []rune("bench"), Group: "commands"},
+				{Insert: []rune("blame"), Group: "commands"},
+				{Insert: []rune("README"), Group: "files"},
+			}, 0
+		}),
+	}, 20)
+	test.Equal(op.OnComplete(), true)
+	rows := strings.Split(op.buf.below[BelowComplete], "\n")
+	for idx := range rows {
+		rows[idx] = strings.TrimRight(rows[idx], " ")
+	}
+	// each group has its own columns
+	test.Equal(rows, []string{
+		"\033[1mcommands\033[0m",
+		"build bench blame",
+		"\033[1moptions\033[0m",
+		"--verbose",
+		"\033[1mfiles\033[0m",
+		"main.go  README",
+	})
+
+	// the selection moves across the groups
+	op.EnterCompleteSelectMode()
+	op.doSelect()
+	selected := func() string { return string(op.candidate[op.candidateChoise].Insert) }
+	test.Equal(selected(), "build")
+	op.HandleCompleteSelect(CharLineEnd)
+	test.Equal(selected(), "blame")
+	op.HandleCompleteSelect(CharNext)
+	test.Equal(selected(), "--verbose")
+	op.HandleCompleteSelect(CharNext)
+	test.Equal(selected(), "main.go")
+	op.HandleCompleteSelect(CharForward)
+	test.Equal(selected(), "README")
+	op.HandleCompleteSelect(CharNext)
+	test.Equal(selected(), "bench")
+	op.HandleCompleteSelect(CharPrev)
+	test.Equal(selected(), "README")
+
+	// the built-in completers mark the groups
+	tree := NewPrefixCompleter(&PrefixCompleter{Name: []rune("build "), Group: "commands"})
+	for i, line := range []string{"b", `"b`, `"build`, "build"} {
+		candidates, _ := tree.DoCandidates([]rune(line), len(line))
+		test.Equal(len(candidates), 1, fmt.Errorf("%v", i))
+		test.Equal(candidates[0].Group, "commands", fmt.Errorf("%v", i))
+	}
+}
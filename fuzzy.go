@@ -0,0 +1,115 @@
+package readline
+
+import (
+	"sort"
+	"unicode"
+)
+
+// scores of FuzzyMatch, borrowed from fzf
+const (
+	fuzzyScoreMatch        = 16
+	fuzzyScoreGapStart     = -3
+	fuzzyScoreGapExtension = -1
+	fuzzyBonusBoundary     = fuzzyScoreMatch / 2
+	fuzzyBonusCamel        = fuzzyBonusBoundary - 1
+	fuzzyBonusConsecutive  = -(fuzzyScoreGapStart + fuzzyScoreGapExtension)
+	fuzzyBonusFirstChar    = 2
+)
+
+func fuzzyBonus(text []rune, j int) int {
+	if j == 0 {
+		return fuzzyBonusBoundary
+	}
+	prev, cur := text[j-1], text[j]
+	if IsWordBreak(prev) && !IsWordBreak(cur) {
+		return fuzzyBonusBoundary
+	}
+	if unicode.IsLower(prev) && unicode.IsUpper(cur) {
+		return fuzzyBonusCamel
+	}
+	return 0
+}
+
+// FuzzyMatch reports whether pattern is a subsequence of text, along with
+// a fzf-like score (higher is better) and the indexes of the matched runes
+// in text. Matches at word boundaries and consecutive matches score higher,
+// gaps score lower.
+func FuzzyMatch(text, pattern []rune, fold bool) (score int, matched []int, ok bool) {
+	n, m := len(text), len(pattern)
+	if m == 0 {
+		return 0, nil, true
+	}
+	if m > n {
+		return 0, nil, false
+	}
+
+	const none = -1 << 30
+	scores := make([][]int, m)
+	from := make([][]int, m)
+	for i := 0; i < m; i++ {
+		scores[i] = make([]int, n)
+		from[i] = make([]int, n)
+		for j := 0; j < n; j++ {
+			scores[i][j] = none
+			if !runes.EqualRune(text[j], pattern[i], fold) {
+				continue
+			}
+			bonus := fuzzyBonus(text, j)
+			if i == 0 {
+				scores[i][j] = fuzzyScoreMatch + bonus*fuzzyBonusFirstChar
+				continue
+			}
+			for k := i - 1; k < j; k++ {
+				if scores[i-1][k] == none {
+					continue
+				}
+				s := scores[i-1][k] + fuzzyScoreMatch
+				if k == j-1 {
+					if bonus < fuzzyBonusConsecutive {
+						s += fuzzyBonusConsecutive
+					} else {
+						s += bonus
+					}
+				} else {
+					s += bonus + fuzzyScoreGapStart + fuzzyScoreGapExtension*(j-k-2)
+				}
+				if s > scores[i][j] {
+					scores[i][j], from[i][j] = s, k
+				}
+			}
+		}
+	}
+
+	end := -1
+	for j := 0; j < n; j++ {
+		if scores[m-1][j] != none && (end < 0 || scores[m-1][j] > scores[m-1][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	score = scores[m-1][end]
+	matched = make([]int, m)
+	for i := m - 1; i >= 0; i-- {
+		matched[i] = end
+		end = from[i][end]
+	}
+	return score, matched, true
+}
+
+// sortCandidates sorts candidates by their scores, the highest first.
+func sortCandidates(candidates []Candidate, scores []int) {
+	idx := make([]int, len(candidates))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return scores[idx[i]] > scores[idx[j]]
+	})
+	sorted := make([]Candidate, len(candidates))
+	for i, j := range idx {
+		sorted[i] = candidates[j]
+	}
+	copy(candidates, sorted)
+}
@@ -0,0 +1,108 @@
+package readline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chzyer/test"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	defer test.New(t)
+
+	ret := []struct {
+		Text    string
+		Pattern string
+		Fold    bool
+		Matched []int
+		Ok      bool
+	}{
+		{"git-checkout", "gco", false, []int{0, 4, 9}, true},
+		{"git-checkout", "gc", false, []int{0, 4}, true},
+		{"git-checkout", "ogc", false, nil, false},
+		{"readline", "rl", false, []int{0, 4}, true},
+		{"ReadLine", "rl", true, []int{0, 4}, true},
+		{"ReadLine", "rl", false, nil, false},
+		{"abc", "", false, nil, true},
+		{"ab", "abc", false, nil, false},
+	}
+	for i, r := range ret {
+		_, matched, ok := FuzzyMatch([]rune(r.Text), []rune(r.Pattern), r.Fold)
+		test.Equal(ok, r.Ok, fmt.Errorf("%v", i))
+		test.Equal(matched, r.Matched, fmt.Errorf("%v", i))
+	}
+
+	// boundaries and consecutive runes score higher than scattered ones
+	prefix, _, _ := FuzzyMatch([]rune("checkout"), []rune("che"), false)
+	boundary, _, _ := FuzzyMatch([]rune("git-checkout"), []rune("che"), false)
+	scattered, _, _ := FuzzyMatch([]rune("cache"), []rune("che"), false)
+	test.Equal(prefix >= boundary, true)
+	test.Equal(boundary > scattered, true)
+}
+
+func TestPrefixCompleterFuzzy(t *testing.T) {
+	defer test.New(t)
+
+	p := NewPrefixCompleter(
+		PcItem("git-checkout",
+			PcItem("master"),
+		),
+		PcItem("git-commit"),
+		PcItem("cache"),
+	)
+	p.Fuzzy = true
+
+	candidates, offset := p.DoCandidates([]rune("gco"), 3)
+	test.Equal(offset, 3)
+	// "co" is consecutive in git-commit
+	test.Equal(rs(CandidateInserts(candidates)), []string{"git-commit ", "git-checkout "})
+	test.Equal(candidates[1].Replace, true)
+	test.Equal(candidates[1].Start, 0)
+	test.Equal(candidates[1].End, 3)
+	test.Equal(candidates[1].Matched, []int{0, 4, 9})
+
+	candidates, _ = p.DoCandidates([]rune("git-checkout mst"), 16)
+	test.Equal(len(candidates), 1)
+	test.Equal(string(candidates[0].Insert), "master ")
+	test.Equal(candidates[0].Start, 13)
+
+	// Do only completes prefixes
+	newLine, _ := p.Do([]rune("gco"), 3)
+	test.Equal(len(newLine), 0)
+
+	op, _ := newTestOperation(&Config{Prompt: "> ", AutoComplete: p}, 80)
+	op.buf.WriteString("gcom")
+	test.Equal(op.OnComplete(), true)
+	test.Equal(string(op.buf.Runes()), "git-commit ")
+
+	op.buf.Set([]rune("gco"))
+	test.Equal(op.OnComplete(), true)
+	test.Equal(op.IsInCompleteMode(), true)
+	test.Equal(strings.Fields(op.buf.below[BelowComplete]), []string{
+		"\033[4mg\033[24mit-\033[4mc\033[24m\033[4mo\033[24mmmit",
+		"\033[4mg\033[24mit-\033[4mc\033[24mheck\033[4mo\033[24mut",
+	})
+	op.EnterCompleteSelectMode()
+	op.doSelect()
+	op.HandleCompleteSelect(CharForward)
+	op.HandleCompleteSelect(CharEnter)
+	test.Equal(string(op.buf.Runes()), "git-checkout ")
+}
+
+func TestSegmentCompleterFuzzy(t *testing.T) {
+	defer test.New(t)
+
+	s := SegmentAutoComplete(&dumpSegmentCompleter{func(seg [][]rune, n int) [][]rune {
+		return sr("add", "remove", "readd")
+	}})
+	s.Fuzzy = true
+
+	candidates, idx := s.DoCandidates([]rune("route ad"), 8)
+	test.Equal(idx, 2)
+	test.Equal(rs(CandidateInserts(candidates)), []string{"d ", "readd "})
+	test.Equal(candidates[0].Replace, false)
+	test.Equal(candidates[1].Replace, true)
+	test.Equal(candidates[1].Start, 6)
+	test.Equal(candidates[1].Matched, []int{2, 3})
+}
@@ -323,6 +323,23 @@ func (o *opHistory) Update(s []rune, commit bool) (err error) {
 	return
 }
 
+// lines returns the submitted lines, the newest first. The current one is
+// the line being edited, it's skipped.
+func (o *opHistory) lines() [][]rune {
+	o.fdLock.Lock()
+	defer o.fdLock.Unlock()
+	ret := [][]rune{}
+	for elem := o.history.Back(); elem != nil; elem = elem.Prev() {
+		if elem == o.current {
+			continue
+		}
+		if source := elem.Value.(*hisItem).Source; len(source) > 0 {
+			ret = append(ret, source)
+		}
+	}
+	return ret
+}
+
 func (o *opHistory) Push(s []rune) {
 	s = runes.Copy(s)
 	elem := o.history.PushBack(&hisItem{Source: s})
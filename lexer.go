@@ -0,0 +1,134 @@
+package readline
+
+import (
+	"strings"
+)
+
+// Word is a word of a line split by SplitWords.
+type Word struct {
+	// Value is the word without its quotes and escapes
+	Value []rune
+	// Start and End are the indexes of the word as typed in the line
+	Start, End int
+	// Quote is the quote which is still open at the end of the word
+	Quote rune
+}
+
+// SplitWords splits line into words like a shell: spaces in single or
+// double quotes or escaped with a backslash don't separate words, and an
+// unterminated quote runs to the end of the line. If line is empty or ends
+// with a separating space, the last word is an empty one at its end.
+func SplitWords(line []rune) []Word {
+	ret := []Word{}
+	w := Word{Value: []rune{}}
+	inWord := false
+	for i := 0; i < len(line); i++ {
+		r := line[i]
+		if !inWord {
+			if w.Quote == 0 && (r == ' ' || r == '\t') {
+				continue
+			}
+			inWord = true
+			w.Start = i
+		}
+		switch {
+		case w.Quote == '\'':
+			if r == '\'' {
+				w.Quote = 0
+			} else {
+				w.Value = append(w.Value, r)
+			}
+		case r == '\\' && i+1 < len(line):
+			if w.Quote == '"' && !strings.ContainsRune("\"\\$`", line[i+1]) {
+				w.Value = append(w.Value, r)
+				break
+			}
+			i++
+			w.Value = append(w.Value, line[i])
+		case w.Quote == '"':
+			if r == '"' {
+				w.Quote = 0
+			} else {
+				w.Value = append(w.Value, r)
+			}
+		case r == '\'' || r == '"':
+			w.Quote = r
+		case r == ' ' || r == '\t':
+			w.End = i
+			ret = append(ret, w)
+			w = Word{Value: []rune{}}
+			inWord = false
+		default:
+			w.Value = append(w.Value, r)
+		}
+	}
+	if !inWord {
+		w.Start = len(line)
+	}
+	w.End = len(line)
+	return append(ret, w)
+}
+
+// wordEnd returns the index where the word under pos ends.
+func wordEnd(line []rune, pos int) int {
+	for _, w := range SplitWords(line) {
+		if w.Start <= pos && pos <= w.End {
+			return w.End
+		}
+	}
+	return pos
+}
+
+// quoteWord escapes s so it can be inserted after an open quote, or
+// unquoted if quote is 0.
+func quoteWord(s string, quote rune) string {
+	buf := make([]rune, 0, len(s))
+	for _, r := range s {
+		switch quote {
+		case 0:
+			if strings.ContainsRune(wordSpecialChars, r) {
+				buf = append(buf, '\\')
+			}
+		case '"':
+			if strings.ContainsRune("\"\\$`", r) {
+				buf = append(buf, '\\')
+			}
+		case '\'':
+			if r == '\'' {
+				// close, escape and reopen
+				buf = append(buf, '\'', '\\', '\'')
+			}
+		}
+		buf = append(buf, r)
+	}
+	return string(buf)
+}
+
+// the runes escaped with a backslash in unquoted words
+const wordSpecialChars = " \t\n\\'\"`$&|;<>()[]{}*?!#"
+
+// quoteCompletion quotes the rest of a word being completed like the typed
+// word. If it ends with a space the word is final and the quote is closed.
+func quoteCompletion(rest []rune, quote rune) []rune {
+	final := len(rest) > 0 && rest[len(rest)-1] == ' '
+	if final {
+		rest = rest[:len(rest)-1]
+	}
+	ret := quoteWord(string(rest), quote)
+	if final {
+		if quote != 0 {
+			ret += string(quote)
+		}
+		ret += " "
+	}
+	return []rune(ret)
+}
+
+// rawCompletion works like quoteCompletion without escaping rest, e.g.
+// for the '}' of "${VAR}".
+func rawCompletion(rest []rune, quote rune) []rune {
+	if len(rest) > 0 && rest[len(rest)-1] == ' ' && quote != 0 {
+		return append(runes.Copy(rest[:len(rest)-1]), quote, ' ')
+	}
+	return rest
+}
@@ -0,0 +1,102 @@
+package readline
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chzyer/test"
+)
+
+func TestSplitWords(t *testing.T) {
+	defer test.New(t)
+
+	ret := []struct {
+		Line   string
+		Values []string
+		Starts []int
+		Quote  rune
+	}{
+		{"", []string{""}, []int{0}, 0},
+		{"a  bc ", []string{"a", "bc", ""}, []int{0, 3, 6}, 0},
+		{`open "My Documents/fi`, []string{"open", "My Documents/fi"}, []int{0, 5}, '"'},
+		{`a\ b c`, []string{"a b", "c"}, []int{0, 5}, 0},
+		{`'it'\''s' "a\"b\c" x`, []string{"it's", `a"b\c`, "x"}, []int{0, 10, 19}, 0},
+		{`say 'hello  `, []string{"say", "hello  "}, []int{0, 4}, '\''},
+		{`a"b c"d`, []string{"ab cd"}, []int{0}, 0},
+		{`trailing\`, []string{`trailing\`}, []int{0}, 0},
+	}
+	for i, r := range ret {
+		words := SplitWords([]rune(r.Line))
+		values := make([]string, len(words))
+		starts := make([]int, len(words))
+		for idx, w := range words {
+			values[idx] = string(w.Value)
+			starts[idx] = w.Start
+		}
+		test.Equal(values, r.Values, fmt.Errorf("%v", i))
+		test.Equal(starts, r.Starts, fmt.Errorf("%v", i))
+		test.Equal(words[len(words)-1].Quote, r.Quote, fmt.Errorf("%v", i))
+		test.Equal(words[len(words)-1].End, len([]rune(r.Line)), fmt.Errorf("%v", i))
+	}
+}
+
+func TestQuotedCompletion(t *testing.T) {
+	defer test.New(t)
+
+	p := NewPrefixCompleter(
+		PcItem("open",
+			PcItemDynamic(func(string) []string {
+				return []string{"My Documents", "Music"}
+			},
+				PcItem("now"),
+			),
+		),
+	)
+	ret := []struct {
+		Line    string
+		Inserts []string
+	}{
+		{`open "My D`, []string{`ocuments" `}},
+		{`open My\ D`, []string{`ocuments `}},
+		{`open 'M`, []string{`y Documents' `, `usic' `}},
+		{`open "My Documents" n`, []string{"ow "}},
+		{`open My\ Documents n`, []string{"ow "}},
+	}
+	for i, r := range ret {
+		newLine, _ := p.Do([]rune(r.Line), len([]rune(r.Line)))
+		test.Equal(rs(newLine), r.Inserts, fmt.Errorf("%v", i))
+	}
+
+	s := SegmentFunc(func(segment [][]rune, n int) [][]rune {
+		if len(segment) == 3 && string(segment[1]) == "My Documents" {
+			return sr("now")
+		}
+		return sr("My Documents", "Music")
+	})
+	ret = []struct {
+		Line    string
+		Inserts []string
+	}{
+		{`open "My D`, []string{`ocuments" `}},
+		{`open My\ D`, []string{`ocuments `}},
+		{`open "My Documents" n`, []string{"ow "}},
+		// unquoted words aren't escaped, unless it's asked
+		{`open My`, []string{" Documents "}},
+	}
+	for i, r := range ret {
+		newLine, _ := s.Do([]rune(r.Line), len([]rune(r.Line)))
+		test.Equal(rs(newLine), r.Inserts, fmt.Errorf("%v", i))
+	}
+	s.(*SegmentComplete).Escape = true
+	newLine, _ := s.Do([]rune("open My"), 7)
+	test.Equal(rs(newLine), []string{`\ Documents `})
+
+	// e.g. SQL
+	sql := SegmentFunc(func(segment [][]rune, n int) [][]rune {
+		return sr("count(*)", "coalesce")
+	})
+	newLine, _ = sql.Do([]rune("select cou"), 10)
+	test.Equal(rs(newLine), []string{"nt(*) "})
+	newLine, _ = NewPrefixCompleter(PcItem("select", PcItem("count(*)"))).Do([]rune("select cou"), 10)
+	test.Equal(rs(newLine), []string{"nt(*) "})
+}
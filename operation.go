@@ -84,6 +84,8 @@ func NewOperation(t *Terminal, cfg *Config) *Operation {
 		op.opCompleter.OnWidthChange(newWidth)
 		op.opSearch.OnWidthChange(newWidth)
 		op.buf.OnWidthChange(newWidth)
+		// repaint at the new width
+		op.RefreshPrompt()
 	})
 	go op.ioloop()
 	return op
@@ -110,6 +112,14 @@ func (o *Operation) ioloop() {
 		keepInCompleteMode := false
 		r := o.t.ReadRune()
 
+		// any key cancels a running completion, Ctrl-C and Ctrl-G only that
+		if o.CancelComplete() && (r == CharInterrupt || r == CharBell) {
+			if r == CharInterrupt {
+				o.t.KickRead()
+			}
+			continue
+		}
+
 		if o.GetConfig().FuncFilterInputRune != nil {
 			var process bool
 			r, process = o.GetConfig().FuncFilterInputRune(r)
@@ -136,6 +146,11 @@ func (o *Operation) ioloop() {
 		}
 		isUpdateHistory := true
 
+		if o.IsInCompleteQueryMode() {
+			o.HandleCompleteQuery(r)
+			continue
+		}
+
 		if o.IsInCompleteSelectMode() {
 			keepInCompleteMode = o.HandleCompleteSelect(r)
 			if keepInCompleteMode {
@@ -243,9 +258,15 @@ func (o *Operation) ioloop() {
 			if o.IsSearchMode() {
 				o.ExitSearchMode(false)
 			}
+			o.buf.SetBelowHidden(true)
 			o.buf.MoveToLineEnd()
 			var data []rune
 			if !o.GetConfig().UniqueEditLine {
+				if f := o.GetConfig().TransientPromptFunc; f != nil {
+					o.buf.SetTransientPrompt(f())
+				} else if p := o.GetConfig().TransientPrompt; p != "" {
+					o.buf.SetTransientPrompt(p)
+				}
 				o.buf.WriteRune('\n')
 				data = o.buf.Reset()
 				data = data[:len(data)-1] // trim \n
@@ -288,6 +309,7 @@ func (o *Operation) ioloop() {
 			}
 
 			// treat as EOF
+			o.buf.SetBelowHidden(true)
 			if !o.GetConfig().UniqueEditLine {
 				o.buf.WriteString(o.GetConfig().EOFPrompt + "\n")
 			}
@@ -310,6 +332,7 @@ func (o *Operation) ioloop() {
 				o.buf.Refresh(nil)
 				break
 			}
+			o.buf.SetBelowHidden(true)
 			o.buf.MoveToLineEnd()
 			o.buf.Refresh(nil)
 			hint := o.GetConfig().InterruptPrompt + "\n"
@@ -329,6 +352,9 @@ func (o *Operation) ioloop() {
 				keepInSearchMode = true
 				break
 			}
+			if r == o.GetConfig().HelpKey && o.ShowHelp() {
+				break
+			}
 			o.buf.WriteRune(r)
 			if o.IsInCompleteMode() {
 				o.OnComplete()
@@ -387,6 +413,7 @@ func (o *Operation) Runes() ([]rune, error) {
 		listener.OnChange(nil, 0, 0)
 	}
 
+	o.buf.SetBelowHidden(false)
 	o.buf.Refresh(nil) // print prompt
 	o.t.KickRead()
 	select {
@@ -506,6 +533,26 @@ func (o *Operation) Refresh() {
 	}
 }
 
+func (o *Operation) SetBelow(section int, content string) {
+	o.buf.SetBelow(section, content)
+	o.Refresh()
+}
+
+func (o *Operation) RefreshPrompt() {
+	if !o.t.IsReading() {
+		return
+	}
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.buf.Refresh(nil)
+	if o.IsSearchMode() {
+		o.SearchRefresh(-1)
+	}
+	if o.IsInCompleteMode() {
+		o.CompleteRefresh()
+	}
+}
+
 func (o *Operation) Clean() {
 	o.buf.Clean()
 }
@@ -19,6 +19,7 @@ package readline
 
 import (
 	"io"
+	"time"
 )
 
 type Instance struct {
@@ -30,6 +31,16 @@ type Instance struct {
 type Config struct {
 	// prompt supports ANSI escape sequence, so we can color some characters even in windows
 	Prompt string
+	// PromptFunc is called on every redraw and its result replaces Prompt,
+	// so the prompt can show a clock, the cwd, etc.
+	// It must not call back into the Instance.
+	PromptFunc func() string
+
+	// TransientPrompt replaces the prompt of a submitted line before the
+	// newline is written, so the scrollback stays clean.
+	// TransientPromptFunc, if set, is used instead.
+	TransientPrompt     string
+	TransientPromptFunc func() string
 
 	// readline will persist historys to file where HistoryFile specified
 	HistoryFile string
@@ -54,7 +65,42 @@ type Config struct {
 	InterruptPrompt string
 	EOFPrompt       string
 
-	FuncGetWidth func() int
+	FuncGetWidth  func() int
+	FuncGetHeight func() int
+
+	// ask "Display all N possibilities? (y or n)" before listing at least
+	// CompletionQueryItems candidates, 100 by default, negative to never ask.
+	// The list is cut to the terminal height and scrolls with the selection.
+	CompletionQueryItems int
+	// ignore the case when matching the candidates of the built-in
+	// completers, the typed word is rewritten with the candidate's case
+	CompletionIgnoreCase bool
+	// list the candidates one per line and preview the selected one in the
+	// line, the typed runes narrow them and Enter accepts one
+	MenuSelect bool
+	// the order of the groups of candidates (Candidate.Group), the others
+	// are listed after them. The candidates without a group come first and
+	// the groups get a header when there are several.
+	CompletionGroupOrder []string
+	// replace the word under the cursor with its expansion on Tab if it
+	// has a $VAR, a leading ~ or a glob pattern, like bash's
+	// glob-expand-word. It's completed if there's nothing to expand.
+	ExpandOnTab bool
+	// cancel a ContextCompleter after CompleteTimeout, if it's not zero
+	CompleteTimeout time.Duration
+	// print the candidates with their descriptions above the prompt when
+	// HelpKey (e.g. '?') is pressed, without changing the line. It's
+	// inserted inside quotes.
+	HelpKey rune
+
+	// TermCaps describes what the terminal can draw, it's looked up from
+	// $TERM if nil. On a terminal without cursor movement (e.g. TERM=dumb)
+	// readline redraws a single row with only '\r', '\b' and spaces.
+	TermCaps *TermCaps
+	// the terminal doesn't rewrap its lines when its width changes, so the
+	// rows are cleaned as they were drawn. Most terminals rewrap them and
+	// it can't be told from $TERM.
+	DisableReflow bool
 
 	Stdin       io.ReadCloser
 	StdinWriter io.Writer
@@ -64,6 +110,10 @@ type Config struct {
 	EnableMask bool
 	MaskRune   rune
 
+	// keep the input on a single row and scroll it horizontally around
+	// the cursor instead of wrapping, '<' and '>' mark the hidden text.
+	HorizontalScroll bool
+
 	// erase the editing line after user submited it
 	// it use in IM usually.
 	UniqueEditLine bool
@@ -130,6 +180,15 @@ func (c *Config) Init() error {
 	if c.FuncGetWidth == nil {
 		c.FuncGetWidth = GetScreenWidth
 	}
+	if c.FuncGetHeight == nil {
+		c.FuncGetHeight = GetScreenHeight
+	}
+	if c.CompletionQueryItems == 0 {
+		c.CompletionQueryItems = 100
+	}
+	if c.TermCaps == nil {
+		c.TermCaps = DetectTermCaps()
+	}
 	if c.FuncIsTerminal == nil {
 		c.FuncIsTerminal = DefaultIsTerminal
 	}
@@ -327,6 +386,25 @@ func (i *Instance) Refresh() {
 	i.Operation.Refresh()
 }
 
+// SetBelow sets a section of the region drawn under the edit line
+// (see BelowStatus), an empty content removes it.
+// The region moves with the input and is erased once the line is submitted.
+func (i *Instance) SetBelow(section int, content string) {
+	i.Operation.SetBelow(section, content)
+}
+
+// SetStatusLine shows s under the edit line until it's changed,
+// e.g. the current mode, hints or a validation error.
+func (i *Instance) SetStatusLine(s string) {
+	i.SetBelow(BelowStatus, s)
+}
+
+// RefreshPrompt repaints the prompt (re-evaluating Config.PromptFunc) with
+// the pending input, it's safe to call from another goroutine.
+func (i *Instance) RefreshPrompt() {
+	i.Operation.RefreshPrompt()
+}
+
 // HistoryDisable the save of the commands into the history
 func (i *Instance) HistoryDisable() {
 	i.Operation.history.Disable()
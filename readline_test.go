@@ -1,10 +1,70 @@
 package readline
 
 import (
+	"bytes"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/chzyer/test"
 )
 
+// readlineOutput reads the lines of keys and returns what was written
+// before the last Enter was read.
+func readlineOutput(t *testing.T, cfg *Config, keys string, lines int) string {
+	w := bytes.NewBuffer(nil)
+	var ret string
+	cfg.Stdin = ioutil.NopCloser(strings.NewReader(keys))
+	cfg.Stdout = w
+	cfg.ForceUseInteractive = true
+	cfg.TermCaps = &TermCaps{Cursor: true, Color: true}
+	cfg.FuncGetWidth = func() int { return 80 }
+	cfg.FuncMakeRaw = func() error { return nil }
+	cfg.FuncExitRaw = func() error { return nil }
+	cfg.FuncOnWidthChanged = func(func()) {}
+	cfg.FuncFilterInputRune = func(r rune) (rune, bool) {
+		if r == CharEnter {
+			ret = w.String()
+		}
+		return r, true
+	}
+	rl, err := NewEx(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+	for i := 0; i < lines; i++ {
+		if _, err := rl.Readline(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ret
+}
+
+// lastDrawn returns what's drawn since the screen was last cleared below
+// the cursor.
+func lastDrawn(output string) string {
+	return output[strings.LastIndex(output, "\033[J"):]
+}
+
+func TestReadlineClearBelow(t *testing.T) {
+	defer test.New(t)
+
+	// the menu is cleared once no candidate is left
+	output := readlineOutput(t, &Config{
+		Prompt:       "> ",
+		AutoComplete: NewPrefixCompleter(PcItem("abc"), PcItem("abd")),
+	}, "a\t\tx\r", 1)
+	test.Equal(strings.Contains(output, "abc  abd"), true)
+	test.Equal(strings.Contains(lastDrawn(output), "abc"), false)
+
+	// so is the search when it's canceled
+	output = readlineOutput(t, &Config{Prompt: "> "}, "abc\r\x12a\x03\r", 2)
+	test.Equal(strings.Contains(output, "bck-i-search"), true)
+	test.Equal(strings.Contains(lastDrawn(output), "bck-i-search"), false)
+}
+
 func TestRace(t *testing.T) {
 	rl, err := NewEx(&Config{})
 	if err != nil {
@@ -4,11 +4,20 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 )
 
+// Sections of the region drawn below the edit line, they are drawn in
+// the order of their keys so user code can pick any key in between.
+const (
+	BelowSearch   = 100
+	BelowComplete = 200
+	BelowStatus   = 300
+)
+
 type runeBufferBck struct {
 	buf []rune
 	idx int
@@ -32,6 +41,24 @@ type RuneBuffer struct {
 
 	lastKill []rune
 
+	// the prompt replaced by SetTransientPrompt, restored by Reset
+	transient []rune
+
+	below       map[int]string
+	belowHidden bool
+
+	// first visible rune in HorizontalScroll mode
+	hscroll int
+	// columns drawn by hscrollOutput, erased by spaces on a dumb terminal
+	drawn int
+
+	// where the cursor was drawn: the widths of the lines of the prompt
+	// and the buffer up to the cursor, and the row it's on. The row is
+	// recalculated when the width changes so the old layout can be cleaned.
+	drawnLines []int
+	drawnRow   int
+	resized    bool
+
 	sync.Mutex
 }
 
@@ -41,6 +68,11 @@ func (r *RuneBuffer) pushKill(text []rune) {
 
 func (r *RuneBuffer) OnWidthChange(newWidth int) {
 	r.Lock()
+	if newWidth > 0 && !r.cfg.DisableReflow {
+		// the terminal rewrapped what we drew at the new width
+		r.drawnRow = rewrappedRow(r.drawnLines, newWidth)
+	}
+	r.resized = true
 	r.width = newWidth
 	r.Unlock()
 }
@@ -170,6 +202,28 @@ func (r *RuneBuffer) WriteRunes(s []rune) {
 	})
 }
 
+// ReplaceRange replaces the runes between start and end with s and moves
+// the cursor after them, the range is clipped to the buffer.
+func (r *RuneBuffer) ReplaceRange(start, end int, s []rune) {
+	r.Refresh(func() {
+		if end > len(r.buf) {
+			end = len(r.buf)
+		}
+		if start < 0 {
+			start = 0
+		}
+		if start > end {
+			return
+		}
+		buf := make([]rune, 0, len(r.buf)-(end-start)+len(s))
+		buf = append(buf, r.buf[:start]...)
+		buf = append(buf, s...)
+		buf = append(buf, r.buf[end:]...)
+		r.buf = buf
+		r.idx = start + len(s)
+	})
+}
+
 func (r *RuneBuffer) MoveForward() {
 	r.Refresh(func() {
 		if r.idx == len(r.buf) {
@@ -381,6 +435,9 @@ func (r *RuneBuffer) MoveToLineEnd() {
 }
 
 func (r *RuneBuffer) LineCount(width int) int {
+	if r.singleRow() {
+		return 1
+	}
 	if width == -1 {
 		width = r.width
 	}
@@ -436,13 +493,43 @@ func (r *RuneBuffer) IdxLine(width int) int {
 }
 
 func (r *RuneBuffer) idxLine(width int) int {
-	if width == 0 {
+	if width == 0 || r.singleRow() {
 		return 0
 	}
 	sp := r.getSplitByLine(r.buf[:r.idx])
 	return len(sp) - 1
 }
 
+// lineWidths returns the widths of the lines of rs, split at '\n'.
+func lineWidths(rs []rune) []int {
+	widths := []int{0}
+	for _, r := range rs {
+		if r == '\n' {
+			widths = append(widths, 0)
+			continue
+		}
+		widths[len(widths)-1] += runes.Width(r)
+	}
+	return widths
+}
+
+// rewrappedRow returns the row of the cursor at the end of the lines,
+// counted from the first one, once they're wrapped at width.
+func rewrappedRow(lines []int, width int) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	row := 0
+	for _, w := range lines[:len(lines)-1] {
+		if w > width {
+			row += LineCount(width, w)
+		} else {
+			row++
+		}
+	}
+	return row + lines[len(lines)-1]/width
+}
+
 func (r *RuneBuffer) CursorLineCount() int {
 	return r.LineCount(r.width) - r.IdxLine(r.width)
 }
@@ -472,11 +559,23 @@ func (r *RuneBuffer) SetOffset(offset string) {
 }
 
 func (r *RuneBuffer) print() {
+	if r.cfg.PromptFunc != nil && r.transient == nil {
+		r.prompt = []rune(r.cfg.PromptFunc())
+	}
 	r.w.Write(r.output())
 	r.hadClean = false
 }
 
+// singleRow reports whether the input is kept on a single row, that's
+// the only layout a dumb terminal can redraw.
+func (r *RuneBuffer) singleRow() bool {
+	return r.cfg.HorizontalScroll || r.cfg.TermCaps.IsDumb()
+}
+
 func (r *RuneBuffer) output() []byte {
+	if r.singleRow() {
+		return r.hscrollOutput()
+	}
 	buf := bytes.NewBuffer(nil)
 	buf.WriteString(string(r.prompt))
 	if r.cfg.EnableMask && len(r.buf) > 0 {
@@ -502,13 +601,244 @@ func (r *RuneBuffer) output() []byte {
 			buf.Write([]byte(" \b"))
 		}
 	}
+	buf.Write(r.belowOutput(r.endColumn()))
 	// cursor position
 	if len(r.buf) > r.idx {
 		buf.Write(r.getBackspaceSequence())
 	}
+	r.drawnLines = lineWidths(append(runes.ColorFilter(r.prompt), r.buf[:r.idx]...))
+	r.drawnRow = r.idxLine(r.width)
+	return buf.Bytes()
+}
+
+// endColumn returns the column right after the buffer.
+func (r *RuneBuffer) endColumn() int {
+	sp := r.getSplitByLine(r.buf)
+	col := runes.WidthAll([]rune(sp[len(sp)-1]))
+	if len(sp) == 1 {
+		col += r.promptLen()
+	}
+	return col
+}
+
+// hscrollOutput draws the prompt and the part of the buffer around the
+// cursor on a single row, with '<' and '>' where text is hidden.
+// A dumb terminal gets the region below the line inline after the input.
+func (r *RuneBuffer) hscrollOutput() []byte {
+	line := r.buf
+	if r.cfg.EnableMask {
+		line = []rune(strings.Repeat(string(r.cfg.MaskRune), len(r.buf)))
+	}
+	prompt := r.prompt
+	if !r.cfg.TermCaps.HasColor() {
+		prompt = runes.ColorFilter(prompt)
+	}
+	var inline []rune
+	if r.cfg.TermCaps.IsDumb() {
+		inline = r.inlineBelow()
+	}
+	start, end := r.hscrollWindow(line, runes.WidthAll(inline))
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(string(prompt))
+	col := r.promptLen()
+	if start > 0 {
+		buf.WriteRune('<')
+		col++
+	}
+	visible := line[start:end]
+	if !r.cfg.EnableMask && r.cfg.TermCaps.HasColor() {
+		visible = r.cfg.Painter.Paint(visible, r.idx-start)
+	}
+	for _, e := range visible {
+		if e == '\t' {
+			buf.WriteString(strings.Repeat(" ", TabWidth))
+		} else {
+			buf.WriteRune(e)
+		}
+	}
+	col += runes.WidthAll(line[start:end])
+	back := runes.WidthAll(line[r.idx:end])
+	if end < len(line) {
+		buf.WriteRune('>')
+		col++
+		back++
+	}
+	if len(inline) > 0 {
+		buf.WriteString(" " + string(inline))
+		col += 1 + runes.WidthAll(inline)
+		back += 1 + runes.WidthAll(inline)
+	} else {
+		buf.Write(r.belowOutput(col))
+	}
+	buf.Write(bytes.Repeat([]byte{'\b'}, back))
+	r.drawn = col
+	r.drawnLines = []int{col - back}
+	r.drawnRow = 0
 	return buf.Bytes()
 }
 
+// inlineBelow joins the first line of the sections under the edit line,
+// clipped to half of the screen.
+func (r *RuneBuffer) inlineBelow() []rune {
+	if r.belowHidden || len(r.below) == 0 {
+		return nil
+	}
+	keys := make([]int, 0, len(r.below))
+	for key := range r.below {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+
+	var ret []rune
+	for _, key := range keys {
+		line := strings.SplitN(r.below[key], "\n", 2)[0]
+		if len(ret) > 0 {
+			ret = append(ret, ' ')
+		}
+		ret = append(ret, runes.ColorFilter([]rune(line))...)
+	}
+	max := (r.width - r.promptLen()) / 2
+	for r.width > 0 && len(ret) > 0 && runes.WidthAll(ret) > max {
+		ret = ret[:len(ret)-1]
+	}
+	return ret
+}
+
+// hscrollWindow returns the range of line to show, keeping the cursor in
+// view and the window where it was as long as possible.
+// reserve columns are kept free after the window.
+func (r *RuneBuffer) hscrollWindow(line []rune, reserve int) (start, end int) {
+	// -1 to avoid reach the end of line
+	avail := r.width - r.promptLen() - 1
+	if reserve > 0 {
+		avail -= reserve + 1
+	}
+	if r.width <= 0 || runes.WidthAll(line) < avail {
+		r.hscroll = 0
+		return 0, len(line)
+	}
+	// keep a column on each side for the markers
+	avail -= 2
+	if avail < 1 {
+		avail = 1
+	}
+
+	start = r.hscroll
+	if start > r.idx {
+		start = r.idx
+	}
+	for start < r.idx && runes.WidthAll(line[start:r.idx])+1 > avail {
+		start++
+	}
+	for start > 0 && runes.WidthAll(line[start-1:])+1 <= avail {
+		start--
+	}
+	end = start
+	for end < len(line) && runes.WidthAll(line[start:end+1]) <= avail {
+		end++
+	}
+	if end < r.idx {
+		end = r.idx
+	}
+	r.hscroll = start
+	return start, end
+}
+
+// belowOutput draws the sections under the edit line and moves the
+// cursor back to the column col of the edit line.
+func (r *RuneBuffer) belowOutput(col int) []byte {
+	if r.belowHidden || len(r.below) == 0 || r.width <= 0 {
+		return nil
+	}
+	keys := make([]int, 0, len(r.below))
+	for key := range r.below {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+
+	buf := bytes.NewBuffer(nil)
+	rows := 0
+	for _, key := range keys {
+		for _, line := range strings.Split(r.below[key], "\n") {
+			buf.WriteString("\n")
+			buf.WriteString(line)
+		}
+		rows += r.sectionRows(key)
+	}
+	buf.WriteString("\033[" + strconv.Itoa(rows) + "A\r")
+	if col > 0 {
+		buf.WriteString("\033[" + strconv.Itoa(col) + "C")
+	}
+	return buf.Bytes()
+}
+
+// sectionRows returns the rows a section takes below the edit line.
+func (r *RuneBuffer) sectionRows(section int) int {
+	rows := 0
+	for _, line := range strings.Split(r.below[section], "\n") {
+		n := LineCount(r.width, runes.WidthAll(runes.ColorFilter([]rune(line))))
+		if n == 0 {
+			n = 1
+		}
+		rows += n
+	}
+	return rows
+}
+
+// BelowRows returns the rows drawn below the edit line by the sections
+// other than except.
+func (r *RuneBuffer) BelowRows(except int) int {
+	r.Lock()
+	defer r.Unlock()
+	if r.belowHidden || r.width <= 0 {
+		return 0
+	}
+	rows := 0
+	for key := range r.below {
+		if key != except {
+			rows += r.sectionRows(key)
+		}
+	}
+	return rows
+}
+
+// SetBelow sets the content of a section drawn under the edit line, it
+// may span several lines and an empty content removes the section.
+// It takes effect on the next Refresh.
+func (r *RuneBuffer) SetBelow(section int, content string) {
+	r.Lock()
+	defer r.Unlock()
+	if content == "" {
+		delete(r.below, section)
+		return
+	}
+	if r.below == nil {
+		r.below = make(map[int]string)
+	}
+	r.below[section] = content
+}
+
+// ClearBelow removes a section and repaints if it was drawn.
+func (r *RuneBuffer) ClearBelow(section int) {
+	r.Lock()
+	_, drawn := r.below[section]
+	drawn = drawn && !r.belowHidden
+	delete(r.below, section)
+	r.Unlock()
+	if drawn {
+		r.Refresh(nil)
+	}
+}
+
+// SetBelowHidden hides the region under the edit line, e.g. once the
+// line is submitted.
+func (r *RuneBuffer) SetBelowHidden(hidden bool) {
+	r.Lock()
+	r.belowHidden = hidden
+	r.Unlock()
+}
+
 func (r *RuneBuffer) getBackspaceSequence() []byte {
 	var sep = map[int]bool{}
 
@@ -543,6 +873,12 @@ func (r *RuneBuffer) Reset() []rune {
 	ret := runes.Copy(r.buf)
 	r.buf = r.buf[:0]
 	r.idx = 0
+	r.drawnLines, r.drawnRow = nil, 0
+	r.resized = false
+	if r.transient != nil {
+		r.prompt = r.transient
+		r.transient = nil
+	}
 	return ret
 }
 
@@ -557,6 +893,10 @@ func (r *RuneBuffer) SetStyle(start, end int, style string) {
 	if end < start {
 		panic("end < start")
 	}
+	// the buffer isn't laid out as is on a single row
+	if r.singleRow() || !r.cfg.TermCaps.HasColor() {
+		return
+	}
 
 	// goto start
 	move := start - r.idx
@@ -568,7 +908,13 @@ func (r *RuneBuffer) SetStyle(start, end int, style string) {
 	r.w.Write([]byte("\033[" + style + "m"))
 	r.w.Write([]byte(string(r.buf[start:end])))
 	r.w.Write([]byte("\033[0m"))
-	// TODO: move back
+
+	// move back
+	if r.idx < end {
+		r.w.Write(runes.Backspace(r.buf[r.idx:end]))
+	} else if r.idx > end {
+		r.w.Write([]byte(string(r.buf[end:r.idx])))
+	}
 }
 
 func (r *RuneBuffer) SetWithIdx(idx int, buf []rune) {
@@ -588,10 +934,23 @@ func (r *RuneBuffer) SetPrompt(prompt string) {
 	r.Unlock()
 }
 
+// SetTransientPrompt redraws the line with prompt, it's kept until the
+// next Reset.
+func (r *RuneBuffer) SetTransientPrompt(prompt string) {
+	r.Refresh(func() {
+		if r.transient == nil {
+			r.transient = r.prompt
+		}
+		r.prompt = []rune(prompt)
+	})
+}
+
 func (r *RuneBuffer) cleanOutput(w io.Writer, idxLine int) {
 	buf := bufio.NewWriter(w)
 
-	if r.width == 0 {
+	if r.cfg.TermCaps.IsDumb() {
+		buf.WriteString("\r" + strings.Repeat(" ", r.drawn) + "\r")
+	} else if r.width == 0 {
 		buf.WriteString(strings.Repeat("\r\b", len(r.buf)+r.promptLen()))
 		buf.Write([]byte("\033[J"))
 	} else {
@@ -617,7 +976,13 @@ func (r *RuneBuffer) Clean() {
 }
 
 func (r *RuneBuffer) clean() {
-	r.cleanWithIdxLine(r.idxLine(r.width))
+	idxLine := r.idxLine(r.width)
+	if r.resized {
+		// what's on screen was laid out for the old width
+		idxLine = r.drawnRow
+		r.resized = false
+	}
+	r.cleanWithIdxLine(idxLine)
 }
 
 func (r *RuneBuffer) cleanWithIdxLine(idxLine int) {
@@ -0,0 +1,159 @@
+package readline
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chzyer/test"
+)
+
+func newTestRuneBuffer(cfg *Config, width int) (*RuneBuffer, *bytes.Buffer) {
+	w := bytes.NewBuffer(nil)
+	cfg.ForceUseInteractive = true
+	if cfg.Painter == nil {
+		cfg.Painter = &defaultPainter{}
+	}
+	return NewRuneBuffer(w, cfg.Prompt, cfg, width), w
+}
+
+func TestRuneBufferPromptFunc(t *testing.T) {
+	defer test.New(t)
+
+	n := 0
+	buf, w := newTestRuneBuffer(&Config{
+		Prompt: "> ",
+		PromptFunc: func() string {
+			n++
+			return strconv.Itoa(n) + "> "
+		},
+	}, 80)
+
+	buf.Refresh(nil)
+	test.Equal(w.String(), "\033[J\033[2K\r1>  \b")
+	w.Reset()
+	buf.WriteString("a")
+	test.Equal(w.String(), "\033[J\033[2K\r2> a")
+	test.Equal(buf.PromptLen(), 3)
+}
+
+func TestRuneBufferTransientPrompt(t *testing.T) {
+	defer test.New(t)
+
+	buf, w := newTestRuneBuffer(&Config{
+		Prompt:     "long> ",
+		PromptFunc: func() string { return "func> " },
+	}, 80)
+	buf.WriteString("ls")
+	w.Reset()
+	buf.SetTransientPrompt("> ")
+	test.Equal(w.String(), "\033[J\033[2K\r> ls")
+	buf.WriteRune('\n')
+	test.Equal(string(buf.Reset()), "ls\n")
+	test.Equal(buf.PromptLen(), len("func> "))
+	w.Reset()
+	buf.Refresh(nil)
+	test.Equal(w.String(), "\033[J\033[2K\rfunc>  \b")
+}
+
+func TestRuneBufferBelow(t *testing.T) {
+	defer test.New(t)
+
+	buf, w := newTestRuneBuffer(&Config{Prompt: "> "}, 10)
+	buf.WriteString("abcdefghij")
+	buf.SetBelow(BelowStatus, "status")
+	buf.SetBelow(BelowSearch, "search")
+	w.Reset()
+	buf.Refresh(nil)
+	test.Equal(w.String(), "\033[J\033[2K\r\033[A\033[2K\r"+
+		"> abcdefghij\nsearch\nstatus\033[2A\r\033[2C")
+
+	buf.SetBelow(BelowSearch, "")
+	w.Reset()
+	buf.MoveToLineStart()
+	test.Equal(w.String(), "\033[J\033[2K\r\033[A\033[2K\r"+
+		"> abcdefghij\nstatus\033[1A\r\033[2C\b\b\b\033[A\r\033[10C\b\b\b\b\b\b\b")
+
+	buf.SetBelowHidden(true)
+	w.Reset()
+	buf.Refresh(nil)
+	test.Equal(w.String(), "\033[J\033[2K\r> abcdefghij\b\b\b\033[A\r\033[10C\b\b\b\b\b\b\b")
+}
+
+func TestRuneBufferHorizontalScroll(t *testing.T) {
+	defer test.New(t)
+
+	buf, w := newTestRuneBuffer(&Config{Prompt: "> ", HorizontalScroll: true}, 12)
+	buf.WriteString("abcdefghijklmnop")
+	test.Equal(buf.LineCount(-1), 1)
+	w.Reset()
+	buf.Refresh(nil)
+	test.Equal(w.String(), "\033[J\033[2K\r> <klmnop")
+
+	w.Reset()
+	buf.MoveToLineStart()
+	test.Equal(w.String(), "\033[J\033[2K\r> abcdefg>"+strings.Repeat("\b", 8))
+
+	w.Reset()
+	buf.SetBelow(BelowStatus, "st")
+	buf.MoveForward()
+	test.Equal(w.String(), "\033[J\033[2K\r> abcdefg>\nst\033[1A\r\033[10C"+strings.Repeat("\b", 7))
+}
+
+func TestRuneBufferDumbTerm(t *testing.T) {
+	defer test.New(t)
+
+	buf, w := newTestRuneBuffer(&Config{
+		Prompt:   "\033[31m>\033[0m ",
+		TermCaps: LookupTermCaps("dumb"),
+	}, 20)
+	buf.WriteString("abc")
+	buf.MoveBackward()
+	w.Reset()
+	buf.Refresh(nil)
+	test.Equal(w.String(), "\r     \r> abc\b")
+
+	buf.SetBelow(BelowSearch, "search: \033[4m \033[0m")
+	w.Reset()
+	buf.Refresh(nil)
+	test.Equal(w.String(), "\r     \r> abc search:  "+strings.Repeat("\b", 11))
+	test.Equal(strings.ContainsRune(w.String(), '\033'), false)
+}
+
+func TestLookupTermCaps(t *testing.T) {
+	defer test.New(t)
+
+	test.Equal(LookupTermCaps("dumb").IsDumb(), true)
+	test.Equal(LookupTermCaps("vt100-am").IsDumb(), false)
+	test.Equal(LookupTermCaps("vt100-am").HasColor(), false)
+	test.Equal(LookupTermCaps("xterm-256color").HasColor(), true)
+	test.Equal((*TermCaps)(nil).IsDumb(), false)
+}
+
+func TestRuneBufferResize(t *testing.T) {
+	defer test.New(t)
+
+	for i, r := range []struct {
+		Prompt        string
+		Width         int
+		DisableReflow bool
+		Output        string
+	}{
+		// rewrapped "> abcdefghijkl" at 5 columns, the cursor is on the 3rd row
+		{"> ", 10, false, "\033[J\033[2K\r\033[A\033[2K\r\033[A\033[2K\r> abcdefghijkl"},
+		// the rows stay as they were drawn at 10 columns
+		{"> ", 10, true, "\033[J\033[2K\r\033[A\033[2K\r> abcdefghijkl"},
+		// "dir" keeps its row, "> abcdefghijkl" takes 2 at 10 columns
+		{"dir\n> ", 20, false, "\033[J\033[2K\r\033[A\033[2K\r\033[A\033[2K\rdir\n> abcdefghijkl"},
+	} {
+		cfg := &Config{Prompt: r.Prompt, DisableReflow: r.DisableReflow}
+		buf, w := newTestRuneBuffer(cfg, r.Width)
+		buf.WriteString("abcdefghijkl")
+		buf.OnWidthChange(r.Width / 2)
+		w.Reset()
+		buf.Refresh(nil)
+		test.Equal(w.String(), r.Output, fmt.Errorf("%v", i))
+	}
+}
@@ -3,7 +3,6 @@ package readline
 import (
 	"bytes"
 	"container/list"
-	"fmt"
 	"io"
 )
 
@@ -113,6 +112,8 @@ func (o *opSearch) SearchMode(dir int) bool {
 }
 
 func (o *opSearch) ExitSearchMode(revert bool) {
+	// before the line is set, which repaints
+	o.buf.SetBelow(BelowSearch, "")
 	if revert {
 		o.history.current = o.source
 		o.buf.Set(o.history.showItem(o.history.current.Value))
@@ -130,21 +131,8 @@ func (o *opSearch) SearchRefresh(x int) {
 	} else if x >= 0 {
 		o.state = S_STATE_FOUND
 	}
-	if x < 0 {
-		x = o.buf.idx
-	}
-	x = o.buf.CurrentWidth(x)
-	x += o.buf.PromptLen()
-	x = x % o.width
-
-	if o.markStart > 0 {
-		o.buf.SetStyle(o.markStart, o.markEnd, "4")
-	}
 
-	lineCnt := o.buf.CursorLineCount()
 	buf := bytes.NewBuffer(nil)
-	buf.Write(bytes.Repeat([]byte("\n"), lineCnt))
-	buf.WriteString("\033[J")
 	if o.state == S_STATE_FAILING {
 		buf.WriteString("failing ")
 	}
@@ -154,11 +142,12 @@ func (o *opSearch) SearchRefresh(x int) {
 		buf.WriteString("fwd")
 	}
 	buf.WriteString("-i-search: ")
-	buf.WriteString(string(o.data))         // keyword
-	buf.WriteString("\033[4m \033[0m")      // _
-	fmt.Fprintf(buf, "\r\033[%dA", lineCnt) // move prev
-	if x > 0 {
-		fmt.Fprintf(buf, "\033[%dC", x) // move forward
+	buf.WriteString(string(o.data))    // keyword
+	buf.WriteString("\033[4m \033[0m") // _
+	o.buf.SetBelow(BelowSearch, buf.String())
+	o.buf.Refresh(nil)
+
+	if o.markStart > 0 && o.cfg.TermCaps.HasColor() {
+		o.buf.SetStyle(o.markStart, o.markEnd, "4")
 	}
-	o.w.Write(buf.Bytes())
 }
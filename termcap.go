@@ -0,0 +1,56 @@
+package readline
+
+import (
+	"os"
+	"strings"
+)
+
+// TermCaps describes which control sequences the terminal understands.
+// A nil *TermCaps means a capable (ANSI) terminal.
+type TermCaps struct {
+	// cursor movement and erasing, e.g. \033[A, \033[C, \033[J, \033[2K
+	Cursor bool
+	// SGR attributes, e.g. colors, underline and reverse video
+	Color bool
+}
+
+var termCapsTable = map[string]TermCaps{
+	"dumb":     {},
+	"emacs":    {},
+	"unknown":  {},
+	"glasstty": {},
+	"tty33":    {},
+	"vt52":     {},
+	"vt100":    {Cursor: true},
+	"vt102":    {Cursor: true},
+	"vt220":    {Cursor: true},
+}
+
+// LookupTermCaps returns the capabilities of the terminal type term
+// (the value of $TERM) from a built-in table.
+func LookupTermCaps(term string) *TermCaps {
+	if caps, ok := termCapsTable[term]; ok {
+		return &caps
+	}
+	// e.g. vt100-am, vt220-8bit
+	if idx := strings.Index(term, "-"); idx > 0 {
+		if caps, ok := termCapsTable[term[:idx]]; ok {
+			return &caps
+		}
+	}
+	return &TermCaps{Cursor: true, Color: true}
+}
+
+func DetectTermCaps() *TermCaps {
+	return LookupTermCaps(os.Getenv("TERM"))
+}
+
+// IsDumb reports whether readline has to redraw with only '\r', '\b'
+// and spaces.
+func (c *TermCaps) IsDumb() bool {
+	return c != nil && !c.Cursor
+}
+
+func (c *TermCaps) HasColor() bool {
+	return c == nil || c.Color
+}
@@ -44,6 +44,23 @@ func GetScreenWidth() int {
 	return w
 }
 
+// get height of the terminal
+func getHeight(stdoutFd int) int {
+	_, rows, err := GetSize(stdoutFd)
+	if err != nil {
+		return -1
+	}
+	return rows
+}
+
+func GetScreenHeight() int {
+	h := getHeight(syscall.Stdout)
+	if h < 0 {
+		h = getHeight(syscall.Stderr)
+	}
+	return h
+}
+
 // ClearScreen clears the console screen
 func ClearScreen(w io.Writer) (int, error) {
 	return w.Write([]byte("\033[H"))
@@ -27,6 +27,14 @@ func GetScreenWidth() int {
 	return int(info.dwSize.x)
 }
 
+func GetScreenHeight() int {
+	info, _ := GetConsoleScreenBufferInfo()
+	if info == nil {
+		return -1
+	}
+	return int(info.srWindow.bottom-info.srWindow.top) + 1
+}
+
 // ClearScreen clears the console screen
 func ClearScreen(_ io.Writer) error {
 	return SetConsoleCursorPosition(&_COORD{0, 0})